@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/outofcache/maybego/internal/maybego"
+)
+
+// Runs a ROM without a window, e.g. for CI:
+// go build -tags headless ./cmd/maybego-headless
+// maybego-headless -frames 600 -screenshot out.png -memdump mem.bin path/to/rom
+
+// Two instances linked over TCP:
+// maybego-headless -link-listen localhost:5000 rom & maybego-headless -link-connect localhost:5000 rom
+
+// Debugging with GDB, which runs the emulator until it detaches:
+// maybego-headless -gdb :2345 rom
+
+// Exit codes: 1 wrong arguments, 2 input could not be read, 3 output could not be written,
+// 4 the test ROM reported a failure, 5 with -test the ROM reported nothing.
+
+var emu *maybego.Emulator
+
+func readROM(path string) []byte {
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("File could not be read")
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	return rom
+}
+
+func connectLink(listen string, connect string) {
+	var link maybego.LinkCable
+	var err error
+	if listen != "" {
+		fmt.Println("Waiting for the link partner on", listen)
+		link, err = maybego.ListenLink(listen)
+	} else {
+		link, err = maybego.DialLink(connect)
+	}
+	if err != nil {
+		fmt.Println("Link could not be connected")
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	emu.ConnectLink(link)
+}
+
+func loadState(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return emu.LoadState(file)
+}
+
+func playMovie(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	movie, err := maybego.ReadMovie(file)
+	if err != nil {
+		return 0, err
+	}
+	return movie.Len(), emu.PlayMovie(movie)
+}
+
+func writeScreenshot(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// with the border on the SGB
+	if emu.IsSGB() {
+		return png.Encode(file, emu.GetSGBFrameImage())
+	}
+	return png.Encode(file, emu.GetPPU().GetFrameImage())
+}
+
+func writeMemoryDump(path string) error {
+	return os.WriteFile(path, maybego.Memory[:], 0666)
+}
+
+func main() {
+	frames := flag.Int("frames", 60, "number of frames to run, at least 1; the length of -movie if not given")
+	testFlag := flag.Bool("test", false, "stop once the test ROM reports a result, exit with 5 if it reported none")
+	screenshot := flag.String("screenshot", "", "write the final frame as PNG to this file")
+	memdump := flag.String("memdump", "", "write the final 64 KiB address space to this file")
+	stateFile := flag.String("state", "", "save state to boot from")
+	movieFile := flag.String("movie", "", "movie to play back, overrides -state")
+	debugFlag := flag.Bool("debug", false, "enables logging")
+	logFile := flag.String("logfile", "", "log output file")
+	logContents := flag.String("logcontent", "", "what to log. Can be a combination of the following\npc\t\tlog pc and opcode information\nreg\t\tlog registers\nflags\tlog flags\nall\t\tlog everything")
+
+	linkListen := flag.String("link-listen", "", "wait for a link cable partner on this address, e.g. localhost:5000")
+	linkConnect := flag.String("link-connect", "", "connect the link cable to a partner waiting on this address")
+	linkRom := flag.String("link-rom", "", "run this ROM in the same process, linked to the first one")
+	model := flag.String("model", "auto", "hardware to emulate: auto (from the ROM header), dmg, cgb or sgb")
+	printerDir := flag.String("printer", "", "attach the Game Boy Printer, writing prints to this directory")
+	gdbAddr := flag.String("gdb", "", "wait for GDB on this address, e.g. :2345, and let it control the emulator")
+
+	flag.Parse()
+	frames_given := false
+	flag.Visit(func(f *flag.Flag) {
+		frames_given = frames_given || f.Name == "frames"
+	})
+	if *frames < 1 {
+		fmt.Println("-frames has to be at least 1")
+		os.Exit(1)
+	}
+	logContentsSplit := strings.Split(*logContents, ",")
+
+	logger := maybego.NewLogger(*debugFlag, *logFile)
+
+	for _, c := range logContentsSplit {
+		if c == "reg" || c == "all" {
+			logger.SetRegFlag(true)
+		}
+
+		if c == "pc" || c == "all" {
+			logger.SetPCFlag(true)
+		}
+
+		if c == "flags" || c == "all" {
+			logger.SetFlagsFlag(true)
+		}
+	}
+
+	if len(flag.Args()) != 1 {
+		fmt.Println("Usage: maybego-headless [-frames n] [-test] [-screenshot file] [-memdump file] [-state file] [-movie file] [-link-listen addr | -link-connect addr | -link-rom file] [-printer dir] [-gdb addr] [-model auto|dmg|cgb|sgb] [-debug] [-logfile file] path/to/rom")
+		os.Exit(1)
+	}
+	rom := readROM(flag.Args()[0])
+
+	models := map[string]maybego.Model{"auto": maybego.ModelAuto, "dmg": maybego.ModelDMG, "cgb": maybego.ModelCGB, "sgb": maybego.ModelSGB}
+	if _, ok := models[*model]; !ok {
+		fmt.Println("Unknown model", *model)
+		os.Exit(1)
+	}
+
+	var local *maybego.LocalLink
+	if *linkRom != "" {
+		partner := readROM(*linkRom)
+		local = maybego.NewLocalLink(logger, models[*model], &rom, &partner)
+		emu = local.Switch(0)
+	} else {
+		emu = maybego.NewEmulator(logger)
+		emu.SetModel(models[*model])
+		emu.LoadRom(&rom)
+	}
+	if *linkListen != "" || *linkConnect != "" {
+		connectLink(*linkListen, *linkConnect)
+	}
+	var printer *maybego.Printer
+	if *printerDir != "" {
+		printer = maybego.NewPrinter(*printerDir)
+		emu.ConnectLink(printer)
+	}
+	if *stateFile != "" {
+		if err := loadState(*stateFile); err != nil {
+			fmt.Println("Save state could not be loaded")
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	if *movieFile != "" {
+		movie_frames, err := playMovie(*movieFile)
+		if err != nil {
+			fmt.Println("Movie could not be played")
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if !frames_given {
+			*frames = movie_frames
+		}
+	}
+	if *gdbAddr != "" {
+		fmt.Println("Waiting for GDB on", *gdbAddr)
+		if err := maybego.ServeGDB(emu, maybego.NewDebugger(emu), *gdbAddr); err != nil {
+			fmt.Println("GDB session failed")
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	test := maybego.WatchTestRom(emu)
+	for range *frames {
+		if local != nil {
+			local.RunFrame()
+		} else {
+			emu.RunFrame()
+		}
+		if *testFlag && test.Result() != maybego.TestRunning {
+			break
+		}
+	}
+
+	if *screenshot != "" {
+		if err := writeScreenshot(*screenshot); err != nil {
+			fmt.Println("Screenshot could not be written")
+			fmt.Println(err)
+			os.Exit(3)
+		}
+	}
+
+	if printer != nil {
+		if err := printer.Close(); err != nil {
+			fmt.Println("Print could not be written")
+			fmt.Println(err)
+			os.Exit(3)
+		}
+	}
+
+	if *memdump != "" {
+		if err := writeMemoryDump(*memdump); err != nil {
+			fmt.Println("Memory dump could not be written")
+			fmt.Println(err)
+			os.Exit(3)
+		}
+	}
+
+	switch test.Result() {
+	case maybego.TestFailed:
+		fmt.Println("Failed")
+		os.Exit(4)
+	case maybego.TestRunning:
+		if *testFlag {
+			fmt.Println("No result after", *frames, "frames")
+			os.Exit(5)
+		}
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !headless
+
 package main
 
 import (
@@ -14,7 +16,7 @@ var ui *maybego.Interface
 
 func loadROM() {
 	if len(flag.Args()) != 1 {
-		fmt.Println("Usage: go run main.go [-debug] [-logfile file] path/to/rom")
+		fmt.Println("Usage: go run main.go [-debug] [-logfile file] [-state file] [-rewind MiB] [-config file] [-link-listen addr | -link-connect addr] path/to/rom")
 		os.Exit(1)
 	}
 
@@ -28,11 +30,17 @@ func loadROM() {
 	}
 
 	ui.LoadRom(&rom)
+	ui.SetRomPath(path)
 }
 
 func main() {
 	debugFlag := flag.Bool("debug", false, "enables logging")
 	logFile := flag.String("logfile", "", "log output file")
+	stateFile := flag.String("state", "", "save state to boot from")
+	configFile := flag.String("config", "", "config file (default: config.toml in the user config directory)")
+	rewindBudget := flag.Int("rewind", maybego.DefaultRewindBudget/(1024*1024), "memory budget for rewinding in MiB, 0 disables it")
+	linkListen := flag.String("link-listen", "", "wait for a link cable partner on this address, e.g. localhost:5000")
+	linkConnect := flag.String("link-connect", "", "connect the link cable to a partner waiting on this address")
 	logContents := flag.String("logcontent", "", "what to log. Can be a combination of the following\npc\t\tlog pc and opcode information\nreg\t\tlog registers\nflags\tlog flags\nall\t\tlog everything")
 
 	flag.Parse()
@@ -54,8 +62,50 @@ func main() {
 		}
 	}
 
+	if *configFile == "" {
+		path, err := maybego.ConfigPath()
+		if err == nil {
+			*configFile = path
+		}
+	}
+	config, err := maybego.LoadConfig(*configFile)
+	if err != nil {
+		fmt.Println("Config could not be read")
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
 	ui = maybego.NewUI(logger)
+	ui.SetConfig(config)
+	ui.SetRewindBudget(*rewindBudget * 1024 * 1024)
 	// TODO: optional argument
 	loadROM()
+	if *stateFile != "" {
+		if err := ui.LoadStateFile(*stateFile); err != nil {
+			fmt.Println("Save state could not be loaded")
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if *linkListen != "" || *linkConnect != "" {
+		connectLink(*linkListen, *linkConnect)
+	}
 	ui.Run()
 }
+
+func connectLink(listen string, connect string) {
+	var link maybego.LinkCable
+	var err error
+	if listen != "" {
+		fmt.Println("Waiting for the link partner on", listen)
+		link, err = maybego.ListenLink(listen)
+	} else {
+		link, err = maybego.DialLink(connect)
+	}
+	if err != nil {
+		fmt.Println("Link could not be connected")
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	ui.ConnectLink(link)
+}
@@ -2,12 +2,14 @@ module github.com/outofcache/maybego
 
 go 1.22
 
-require github.com/veandco/go-sdl2 v0.4.35
+require (
+	fyne.io/fyne/v2 v2.7.0
+	github.com/BurntSushi/toml v1.5.0
+	github.com/veandco/go-sdl2 v0.4.35
+)
 
 require (
-	fyne.io/fyne/v2 v2.7.0 // indirect
 	fyne.io/systray v1.11.1-0.20250603113521-ca66a66d8b58 // indirect
-	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v1.1.1 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
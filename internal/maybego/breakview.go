@@ -0,0 +1,113 @@
+//go:build !headless
+
+package maybego
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Lists the breakpoints of the debugger with their hit counts, to enable,
+// disable or delete them, and a form to add breakpoints with a label and a
+// condition, or tracepoints if a log message is given.
+type breakpointView struct {
+	container *fyne.Container
+	list      *widget.List
+	debugger  *Debugger
+	// called when a breakpoint was added, changed or deleted
+	on_change func()
+}
+
+// One breakpoint of the list: enabled, description and delete button.
+type breakpointRow struct {
+	widget.BaseWidget
+	enabled *widget.Check
+	text    *widget.Label
+	remove  *widget.Button
+	bp      *Breakpoint
+}
+
+func createBreakpointView(debugger *Debugger, window fyne.Window) *breakpointView {
+	view := &breakpointView{debugger: debugger}
+	view.list = widget.NewList(
+		func() int { return len(debugger.Breakpoints()) },
+		func() fyne.CanvasObject { return newBreakpointRow(view) },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*breakpointRow).update(debugger.Breakpoints()[id])
+		},
+	)
+
+	address := widget.NewEntry()
+	address.SetPlaceHolder("0150")
+	label := widget.NewEntry()
+	label.SetPlaceHolder("label")
+	condition := widget.NewEntry()
+	condition.SetPlaceHolder("A == 0x3F && LY == 144")
+	message := widget.NewEntry()
+	message.SetPlaceHolder("log and continue, e.g. A={A}")
+	add := widget.NewButtonWithIcon("Break", theme.ContentAddIcon(), func() {
+		var bp *Breakpoint
+		adr, err := parseHex(address.Text, 16)
+		if err == nil {
+			if message.Text != "" {
+				bp, err = debugger.AddTracepoint(uint16(adr), condition.Text, message.Text)
+			} else {
+				bp, err = debugger.AddBreakpoint(uint16(adr), condition.Text)
+			}
+		}
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		bp.Label = label.Text
+		view.changed()
+	})
+
+	title := widget.NewLabel("Breakpoints")
+	title.TextStyle.Bold = true
+	form := container.NewBorder(nil, nil, address, add, container.NewGridWithColumns(3, label, condition, message))
+	scroll := container.NewGridWrap(fyne.NewSize(360, 100), view.list)
+	view.container = container.NewVBox(title, form, scroll)
+	return view
+}
+
+func (view *breakpointView) changed() {
+	view.list.Refresh()
+	if view.on_change != nil {
+		view.on_change()
+	}
+}
+
+func newBreakpointRow(view *breakpointView) *breakpointRow {
+	row := &breakpointRow{text: widget.NewLabel("")}
+	row.enabled = widget.NewCheck("", func(enabled bool) {
+		if row.bp != nil && row.bp.Disabled == enabled {
+			row.bp.Disabled = !enabled
+			view.changed()
+		}
+	})
+	row.remove = widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		if row.bp != nil {
+			view.debugger.RemoveBreakpoint(row.bp)
+			row.bp = nil
+			view.changed()
+		}
+	})
+	row.ExtendBaseWidget(row)
+	return row
+}
+
+func (row *breakpointRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewBorder(nil, nil, row.enabled, row.remove, row.text))
+}
+
+func (row *breakpointRow) update(bp *Breakpoint) {
+	// unset first, so setting the check does not change the previous breakpoint
+	row.bp = nil
+	row.enabled.SetChecked(!bp.Disabled)
+	row.text.SetText(bp.String())
+	row.bp = bp
+}
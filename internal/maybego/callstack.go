@@ -0,0 +1,35 @@
+package maybego
+
+// How deep the shadow call stack gets before the oldest calls are dropped,
+// e.g. for code that calls and leaves the stack with a jump.
+const maxCallDepth = 256
+
+// A call on the shadow call stack the CPU keeps for the debugger next to the real stack.
+type CallFrame struct {
+	// address of the CALL or RST, or the instruction an interrupt came in before
+	Caller uint16
+	Target uint16
+	// where the return address was pushed, the RET popping it from there ends the call
+	SP        uint16
+	Interrupt bool
+}
+
+// Returns the calls the CPU is in, the innermost last.
+func (emu *Emulator) CallStack() []CallFrame {
+	return emu.cpu.calls
+}
+
+func (cpu *CPU) pushCall(caller uint16, target uint16, interrupt bool) {
+	if len(cpu.calls) == maxCallDepth {
+		cpu.calls = cpu.calls[1:]
+	}
+	cpu.calls = append(cpu.calls, CallFrame{Caller: caller, Target: target, SP: cpu.reg.SP, Interrupt: interrupt})
+}
+
+// Called before a return pops from SP. It ends the call that pushed there, and the calls
+// deeper in the stack that never returned, e.g. because their return address was popped.
+func (cpu *CPU) popCalls() {
+	for len(cpu.calls) > 0 && cpu.calls[len(cpu.calls)-1].SP <= cpu.reg.SP {
+		cpu.calls = cpu.calls[:len(cpu.calls)-1]
+	}
+}
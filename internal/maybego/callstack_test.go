@@ -0,0 +1,105 @@
+package maybego
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// 0100: CALL 0110; RST 08; HALT
+// 0008: RET
+// 0110: CALL 0120; RET
+// 0120: NOP; RET
+func loadCallProgram() *Emulator {
+	rom := programRom(0xCD, 0x10, 0x01, 0xCF, 0x76)
+	rom[0x08] = 0xC9
+	copy(rom[0x110:], []byte{0xCD, 0x20, 0x01, 0xC9})
+	copy(rom[0x120:], []byte{0x00, 0xC9})
+	return loadProgram(rom, ModelAuto)
+}
+
+func TestCallStack(t *testing.T) {
+	emu := loadCallProgram()
+	outer := CallFrame{Caller: 0x100, Target: 0x110, SP: 0xFFFC}
+	inner := CallFrame{Caller: 0x110, Target: 0x120, SP: 0xFFFA}
+	rst := CallFrame{Caller: 0x103, Target: 0x08, SP: 0xFFFC}
+	steps := []struct {
+		pc       uint16
+		expected []CallFrame
+	}{
+		{0x110, []CallFrame{outer}},
+		{0x120, []CallFrame{outer, inner}},
+		{0x121, []CallFrame{outer, inner}},
+		{0x113, []CallFrame{outer}},
+		{0x103, nil},
+		{0x008, []CallFrame{rst}},
+		{0x104, nil},
+	}
+	for _, step := range steps {
+		emu.Run()
+		if emu.cpu.reg.PC != step.pc {
+			t.Fatalf("Current PC: %x; expected: %x", emu.cpu.reg.PC, step.pc)
+		}
+		if calls := emu.CallStack(); !slices.Equal(calls, step.expected) {
+			t.Errorf("Current calls at %x: %+v; expected: %+v", step.pc, calls, step.expected)
+		}
+	}
+}
+
+func TestCallStackInterrupt(t *testing.T) {
+	emu := loadCallProgram()
+	// NOP; RETI at the VBlank vector
+	DebugWrite(0x40, 0x00)
+	DebugWrite(0x41, 0xD9)
+	Write(IE, 0x01)
+	Write(IF, 0x01)
+	emu.cpu.flg.IME = true
+
+	// the interrupt is dispatched, its handler runs from the next step on
+	emu.Run()
+	expected := []CallFrame{{Caller: 0x100, Target: 0x40, SP: 0xFFFC, Interrupt: true}}
+	if calls := emu.CallStack(); !slices.Equal(calls, expected) || emu.cpu.reg.PC != 0x40 {
+		t.Errorf("Current calls at %x: %+v; expected: %+v at 40", emu.cpu.reg.PC, calls, expected)
+	}
+	emu.Run()
+	emu.Run()
+	if calls := emu.CallStack(); len(calls) != 0 || emu.cpu.reg.PC != 0x100 {
+		t.Errorf("Current calls after RETI at %x: %+v; expected none at 100", emu.cpu.reg.PC, calls)
+	}
+}
+
+// A return from further up the stack ends the calls that never returned.
+func TestCallStackUnwind(t *testing.T) {
+	emu := loadCallProgram()
+	emu.Run()
+	emu.Run()
+	// drop the return address of the inner call and return from the outer one
+	emu.cpu.reg.SP = 0xFFFC
+	emu.cpu.reg.PC = 0x113
+	emu.Run()
+	if calls := emu.CallStack(); len(calls) != 0 || emu.cpu.reg.PC != 0x103 {
+		t.Errorf("Current calls at %x: %+v; expected none at 103", emu.cpu.reg.PC, calls)
+	}
+}
+
+// Loading a state, e.g. while rewinding, brings back the calls it was saved in.
+func TestCallStackSaveState(t *testing.T) {
+	emu := loadCallProgram()
+	emu.Run()
+	emu.Run()
+	expected := slices.Clone(emu.CallStack())
+	var buffer bytes.Buffer
+	if err := emu.SaveState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 4 {
+		emu.Run()
+	}
+	if err := emu.LoadState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if calls := emu.CallStack(); len(calls) != 2 || !slices.Equal(calls, expected) {
+		t.Errorf("Current calls after loading: %+v; expected: %+v", calls, expected)
+	}
+}
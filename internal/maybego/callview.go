@@ -0,0 +1,66 @@
+//go:build !headless
+
+package maybego
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Lists the shadow call stack, the innermost call first, with the symbols of
+// the caller and target. Selecting a call shows its caller in the disassembly.
+type callStackView struct {
+	container *fyne.Container
+	list      *widget.List
+	emu       *Emulator
+	debugger  *Debugger
+	// the calls at the last refresh
+	calls []CallFrame
+}
+
+func createCallStackView(emu *Emulator, debugger *Debugger, disasm *disasmWindow) *callStackView {
+	view := &callStackView{emu: emu, debugger: debugger}
+	view.list = widget.NewList(
+		func() int { return len(view.calls) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(view.describe(view.calls[len(view.calls)-1-id]))
+		},
+	)
+	view.list.OnSelected = func(id widget.ListItemID) {
+		disasm.show(view.calls[len(view.calls)-1-id].Caller)
+		if disasm.on_navigate != nil {
+			disasm.on_navigate()
+		}
+		view.list.UnselectAll()
+	}
+
+	title := widget.NewLabel("Call stack")
+	title.TextStyle.Bold = true
+	scroll := container.NewGridWrap(fyne.NewSize(360, 100), view.list)
+	view.container = container.NewVBox(title, scroll)
+	return view
+}
+
+// Shows the calls the CPU is in now.
+func (view *callStackView) refresh() {
+	view.calls = append(view.calls[:0], view.emu.CallStack()...)
+	view.list.Refresh()
+}
+
+// e.g. "0150 Main+3 -> 4000 Update", or "0213 -> int 0040 VBlank" for an interrupt.
+func (view *callStackView) describe(call CallFrame) string {
+	address := func(adr uint16) string {
+		if name := view.debugger.Symbol(adr); name != "" {
+			return fmt.Sprintf("%04X %s", adr, name)
+		}
+		return fmt.Sprintf("%04X", adr)
+	}
+	if call.Interrupt {
+		return fmt.Sprintf("%s -> int %s", address(call.Caller), address(call.Target))
+	}
+	return fmt.Sprintf("%s -> %s", address(call.Caller), address(call.Target))
+}
@@ -0,0 +1,310 @@
+package maybego
+
+import (
+	"image/color"
+)
+
+const (
+	KEY1 uint16 = 0xFF4D // Prepare speed switch
+	VBK  uint16 = 0xFF4F // VRAM bank
+	BCPS uint16 = 0xFF68 // BG palette index
+	BCPD uint16 = 0xFF69 // BG palette data
+	OCPS uint16 = 0xFF6A // OBJ palette index
+	OCPD uint16 = 0xFF6B // OBJ palette data
+	SVBK uint16 = 0xFF70 // WRAM bank
+	// VRAM DMA
+	HDMA1 uint16 = 0xFF51 // Source high
+	HDMA2 uint16 = 0xFF52 // Source low
+	HDMA3 uint16 = 0xFF53 // Destination high
+	HDMA4 uint16 = 0xFF54 // Destination low
+	HDMA5 uint16 = 0xFF55 // Length, mode and start
+	// header byte with bit 7 set for games supporting the Game Boy Color
+	CGB_FLAG uint16 = 0x143
+)
+
+// The hardware to emulate.
+type Model byte
+
+const (
+	ModelAuto Model = iota // CGB if the ROM header supports it
+	ModelDMG
+	ModelCGB
+	ModelSGB
+)
+
+// Banked memory of the Game Boy Color. VRAM bank 0 and WRAM bank 1 stay in
+// Memory, so code that reads Memory directly keeps seeing the DMG layout.
+// The selected banks are the values of the VBK and SVBK registers in Memory.
+// Palette RAM holds 8 palettes of 4 little-endian RGB555 colors each.
+// No objects are drawn yet, the OBJ palettes are only kept for OCPD reads.
+type cgbMemory struct {
+	enabled bool
+	// a CGB running a DMG game, only the palettes set up by the boot rom are used
+	compat       bool
+	vram1        [0x2000]byte
+	wram         [8][0x1000]byte // banks 2-7, 0 and 1 are unused
+	bg_palettes  [64]byte
+	obj_palettes [64]byte
+	hdma         vramDMA
+}
+
+// A VRAM DMA copies blocks of 16 bytes, all at once (general purpose DMA)
+// or one block per HBlank (HBlank DMA). The CPU waits for every block.
+type vramDMA struct {
+	source      uint16
+	destination uint16 // offset into VRAM
+	blocks      uint16 // left to copy
+	active      bool   // an HBlank DMA is running
+	stall       uint   // cpu cycles left to wait
+}
+
+// The colors the CGB boot rom gives DMG games it has no palette for.
+var compatPalettes = struct {
+	bg [4]color.RGBA
+}{
+	bg: [4]color.RGBA{{0xFF, 0xFF, 0xFF, 0xFF}, {0x7B, 0xFF, 0x31, 0xFF}, {0x00, 0x63, 0xC5, 0xFF}, {0x00, 0x00, 0x00, 0xFF}},
+}
+
+var cgb cgbMemory
+
+// Forces DMG, CGB or SGB mode, takes effect with the next LoadRom or PowerOn.
+func (emu *Emulator) SetModel(model Model) {
+	emu.model = model
+}
+
+// Returns true while emulating a Game Boy Color, also when it runs a DMG game.
+func (emu *Emulator) IsCGB() bool {
+	return cgbHardware()
+}
+
+func cgbHardware() bool {
+	return cgb.enabled || cgb.compat
+}
+
+// A DMG game forced onto a CGB runs in compatibility mode, like on the real hardware.
+// Games for both the CGB and the SGB run on the CGB.
+func (emu *Emulator) selectModel() {
+	cgb = cgbMemory{}
+	sgb = sgbState{}
+	cgb_rom := len(emu.rom) > int(CGB_FLAG) && emu.rom[CGB_FLAG]&0x80 != 0
+	switch emu.model {
+	case ModelAuto:
+		cgb.enabled = cgb_rom
+		if !cgb_rom && sgbRom(emu.rom) {
+			resetSGB()
+		}
+	case ModelCGB:
+		cgb.enabled = cgb_rom
+		cgb.compat = !cgb_rom
+	case ModelSGB:
+		resetSGB()
+	}
+
+	if cgb.enabled {
+		Memory[KEY1] = 0x7E
+		Memory[VBK] = 0xFE
+		Memory[SVBK] = 0xF8
+		Memory[HDMA5] = 0xFF
+		Memory[BCPS] = 0x40
+		Memory[OCPS] = 0x40
+		// the boot rom clears the background to white
+		for i := range cgb.bg_palettes {
+			cgb.bg_palettes[i] = 0xFF
+		}
+	}
+	if cgb.compat {
+		setPalette(&cgb.bg_palettes, 0, compatPalettes.bg)
+	}
+}
+
+func rgb555(c color.RGBA) uint16 {
+	return uint16(c.R>>3) | uint16(c.G>>3)<<5 | uint16(c.B>>3)<<10
+}
+
+// Expands a RGB555 color to 8 bits per channel.
+func rgba(c uint16) color.RGBA {
+	expand := func(v uint16) uint8 {
+		v &= 0x1F
+		return uint8(v<<3 | v>>2)
+	}
+	return color.RGBA{R: expand(c), G: expand(c >> 5), B: expand(c >> 10), A: 0xFF}
+}
+
+func setPalette(palettes *[64]byte, palette byte, colors [4]color.RGBA) {
+	for i, c := range colors {
+		value := rgb555(c)
+		palettes[int(palette)*8+i*2] = byte(value)
+		palettes[int(palette)*8+i*2+1] = byte(value >> 8)
+	}
+}
+
+func paletteColor(palettes *[64]byte, palette byte, index byte) uint16 {
+	offset := int(palette&0x7)*8 + int(index&0x3)*2
+	return (uint16(palettes[offset]) | uint16(palettes[offset+1])<<8) & 0x7FFF
+}
+
+// Writes palette RAM through its data register, the index register
+// increments afterwards if its bit 7 is set.
+func writePaletteData(palettes *[64]byte, index_register uint16, val byte) {
+	index := Memory[index_register]
+	palettes[index&0x3F] = val
+	if index&0x80 != 0 {
+		Memory[index_register] = index&0x80 | 0x40 | (index+1)&0x3F
+	}
+}
+
+// The CPU and timers run twice as fast, everything else keeps its speed.
+func doubleSpeed() bool {
+	return cgb.enabled && Memory[KEY1]&0x80 != 0
+}
+
+// Switches the speed if it was prepared through KEY1, called by STOP.
+func switchSpeed() {
+	if !cgb.enabled || Memory[KEY1]&0x1 == 0 {
+		return
+	}
+	Memory[KEY1] = (Memory[KEY1] ^ 0x80) &^ 0x1
+}
+
+// Returns the switchable WRAM bank, where bank 0 selects bank 1.
+func wramBank() byte {
+	return max(Memory[SVBK]&0x7, 1)
+}
+
+// Returns the banked memory adr is mapped to in CGB mode and the offset
+// into it, nil for Memory.
+func cgbBank(adr uint16) ([]byte, uint16) {
+	switch {
+	case adr >= 0x8000 && adr < 0xA000:
+		if Memory[VBK]&0x1 != 0 {
+			return cgb.vram1[:], adr - 0x8000
+		}
+	case adr >= 0xD000 && adr < 0xE000:
+		if bank := wramBank(); bank != 1 {
+			return cgb.wram[bank][:], adr - 0xD000
+		}
+	}
+	return nil, 0
+}
+
+// Reads VRAM of the given bank, independent of VBK.
+func vramRead(bank byte, adr uint16) byte {
+	if bank == 1 {
+		return cgb.vram1[adr-0x8000]
+	}
+	return Memory[adr]
+}
+
+func cgbRead(adr uint16) byte {
+	switch adr {
+	case HDMA1, HDMA2, HDMA3, HDMA4:
+		return 0xFF
+	case BCPD:
+		return cgb.bg_palettes[Memory[BCPS]&0x3F]
+	case OCPD:
+		return cgb.obj_palettes[Memory[OCPS]&0x3F]
+	}
+	if bank, offset := cgbBank(adr); bank != nil {
+		return bank[offset]
+	}
+	return Memory[adr]
+}
+
+func cgbWrite(adr uint16, val byte) {
+	switch adr {
+	case KEY1:
+		Memory[KEY1] = Memory[KEY1]&0x80 | 0x7E | val&0x1
+		return
+	case VBK:
+		Memory[VBK] = 0xFE | val&0x1
+		return
+	case SVBK:
+		Memory[SVBK] = 0xF8 | val&0x7
+		return
+	case HDMA5:
+		startVramDMA(val)
+		return
+	case BCPS, OCPS:
+		Memory[adr] = val | 0x40
+		return
+	case BCPD:
+		writePaletteData(&cgb.bg_palettes, BCPS, val)
+		return
+	case OCPD:
+		writePaletteData(&cgb.obj_palettes, OCPS, val)
+		return
+	}
+	if bank, offset := cgbBank(adr); bank != nil {
+		bank[offset] = val
+		return
+	}
+	Memory[adr] = val
+}
+
+// Handles a write to HDMA5. Bit 7 selects HBlank DMA, the lower bits
+// the number of blocks - 1. Writing bit 7 as 0 during an HBlank DMA stops it.
+func startVramDMA(val byte) {
+	dma := &cgb.hdma
+	if dma.active && val&0x80 == 0 {
+		dma.active = false
+		Memory[HDMA5] = 0x80 | byte(dma.blocks-1)
+		return
+	}
+
+	dma.source = (uint16(Memory[HDMA1])<<8 | uint16(Memory[HDMA2])) & 0xFFF0
+	dma.destination = (uint16(Memory[HDMA3])<<8 | uint16(Memory[HDMA4])) & 0x1FF0
+	dma.blocks = uint16(val&0x7F) + 1
+
+	if val&0x80 != 0 {
+		dma.active = true
+		Memory[HDMA5] = val & 0x7F
+		return
+	}
+	for dma.blocks > 0 {
+		copyVramBlock()
+	}
+	Memory[HDMA5] = 0xFF
+}
+
+// Copies 16 bytes into the selected VRAM bank.
+func copyVramBlock() {
+	dma := &cgb.hdma
+	// the DMA copies, not the instruction that started it, so watchpoints do not see it
+	for i := range uint16(0x10) {
+		DebugWrite(0x8000|(dma.destination+i)&0x1FFF, DebugRead(dma.source+i))
+	}
+	dma.source += 0x10
+	dma.destination = (dma.destination + 0x10) & 0x1FFF
+	dma.blocks--
+
+	// 8 cycles of the normal speed per block, twice as many cpu cycles in double speed
+	if doubleSpeed() {
+		dma.stall += 16
+	} else {
+		dma.stall += 8
+	}
+}
+
+// Called by the PPU when it enters HBlank on a visible line.
+func hblankVramDMA() {
+	dma := &cgb.hdma
+	if !cgb.enabled || !dma.active {
+		return
+	}
+	copyVramBlock()
+	if dma.blocks == 0 {
+		dma.active = false
+		Memory[HDMA5] = 0xFF
+	} else {
+		Memory[HDMA5] = byte(dma.blocks - 1)
+	}
+}
+
+// Returns true while the CPU waits for a VRAM DMA and counts down one cycle.
+func vramDMAStall() bool {
+	if cgb.hdma.stall == 0 {
+		return false
+	}
+	cgb.hdma.stall--
+	return true
+}
@@ -0,0 +1,243 @@
+package maybego
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Loads an empty ROM with the given CGB flag, cgb is reset once the test is done.
+func loadCgbRom(t *testing.T, flag byte, model Model) *Emulator {
+	t.Helper()
+	t.Cleanup(func() { cgb = cgbMemory{} })
+
+	rom := programRom()
+	rom[CGB_FLAG] = flag
+	return loadProgram(rom, model)
+}
+
+func TestSelectModel(t *testing.T) {
+	tests := []struct {
+		flag     byte
+		model    Model
+		expected bool
+	}{
+		{0x00, ModelAuto, false},
+		{0x80, ModelAuto, true},
+		{0xC0, ModelAuto, true},
+		{0x80, ModelDMG, false},
+		{0x00, ModelCGB, true},
+	}
+
+	for _, test := range tests {
+		emu := loadCgbRom(t, test.flag, test.model)
+		if emu.IsCGB() != test.expected {
+			t.Errorf("Flag %x, model %d: got CGB %t, expected %t", test.flag, test.model, emu.IsCGB(), test.expected)
+		}
+		expected_a := byte(0x01)
+		if test.expected {
+			expected_a = 0x11
+		}
+		if emu.cpu.reg.A != expected_a {
+			t.Errorf("Flag %x, model %d: current A: %x; expected: %x", test.flag, test.model, emu.cpu.reg.A, expected_a)
+		}
+	}
+}
+
+func TestVramBanks(t *testing.T) {
+	loadCgbRom(t, 0x80, ModelAuto)
+
+	Write(0x8010, 0xAA)
+	Write(VBK, 0x01)
+	if Read(VBK) != 0xFF {
+		t.Errorf("Current VBK: %x; expected: %x", Read(VBK), 0xFF)
+	}
+	if Read(0x8010) != 0x00 {
+		t.Errorf("Bank 1 at 0x8010: %x; expected: %x", Read(0x8010), 0x00)
+	}
+	Write(0x8010, 0xBB)
+	if vramRead(0, 0x8010) != 0xAA || vramRead(1, 0x8010) != 0xBB {
+		t.Errorf("Got banks %x %x, expected AA BB", vramRead(0, 0x8010), vramRead(1, 0x8010))
+	}
+
+	Write(VBK, 0x00)
+	if Read(0x8010) != 0xAA {
+		t.Errorf("Bank 0 at 0x8010: %x; expected: %x", Read(0x8010), 0xAA)
+	}
+}
+
+func TestWramBanks(t *testing.T) {
+	loadCgbRom(t, 0x80, ModelAuto)
+
+	for bank := range byte(8) {
+		Write(SVBK, bank)
+		Write(0xD000, 0x10+bank)
+	}
+	// bank 0 selects bank 1, which the write for bank 1 overwrote
+	expected := []byte{0x11, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17}
+	for bank := range byte(8) {
+		Write(SVBK, bank)
+		if Read(0xD000) != expected[bank] {
+			t.Errorf("Bank %d at 0xD000: %x; expected: %x", bank, Read(0xD000), expected[bank])
+		}
+	}
+	if Read(SVBK) != 0xFF {
+		t.Errorf("Current SVBK: %x; expected: %x", Read(SVBK), 0xFF)
+	}
+	// bank 0 is fixed
+	Write(0xC000, 0x42)
+	Write(SVBK, 2)
+	if Read(0xC000) != 0x42 {
+		t.Errorf("Current byte at 0xC000: %x; expected: %x", Read(0xC000), 0x42)
+	}
+}
+
+func TestDmgIgnoresBanks(t *testing.T) {
+	loadCgbRom(t, 0x00, ModelAuto)
+
+	Write(0x8010, 0xAA)
+	Write(VBK, 0x01)
+	if Read(0x8010) != 0xAA {
+		t.Errorf("Current byte at 0x8010: %x; expected: %x", Read(0x8010), 0xAA)
+	}
+}
+
+func TestSpeedSwitch(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+
+	Write(KEY1, 0x01)
+	if Read(KEY1) != 0x7F {
+		t.Errorf("Current KEY1: %x; expected: %x", Read(KEY1), 0x7F)
+	}
+	emu.cpu.reg.PC = 0xC000
+	Write(0xC000, 0x10) // STOP
+	emu.FetchDecodeExec()
+	if Read(KEY1) != 0xFE || !doubleSpeed() {
+		t.Errorf("Current KEY1: %x; expected: %x", Read(KEY1), 0xFE)
+	}
+
+	// the PPU keeps its speed, so it advances half as much per cpu cycle
+	emu.ppu.dots = 0
+	emu.ppu.Render(4)
+	if emu.ppu.dots != 8 {
+		t.Errorf("Current dots: %d; expected: %d", emu.ppu.dots, 8)
+	}
+}
+
+func TestCgbSaveState(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+	Write(VBK, 0x01)
+	Write(0x9000, 0x12)
+	Write(SVBK, 0x05)
+	Write(0xD123, 0x34)
+
+	var state bytes.Buffer
+	if err := emu.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	cgb = cgbMemory{}
+	if err := emu.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	if !emu.IsCGB() || Read(0x9000) != 0x12 || Read(0xD123) != 0x34 {
+		t.Errorf("Got CGB %t, banked bytes %x %x, expected true 12 34", emu.IsCGB(), Read(0x9000), Read(0xD123))
+	}
+}
+
+func TestPaletteRam(t *testing.T) {
+	loadCgbRom(t, 0x80, ModelAuto)
+
+	Write(BCPS, 0x80|0x3E)
+	Write(BCPD, 0x12)
+	Write(BCPD, 0x34) // wraps around to 0
+	Write(BCPD, 0x56)
+	if Read(BCPS) != 0xC1 {
+		t.Errorf("Current BCPS: %x; expected: %x", Read(BCPS), 0xC1)
+	}
+	if cgb.bg_palettes[0x3E] != 0x12 || cgb.bg_palettes[0x3F] != 0x34 || cgb.bg_palettes[0x00] != 0x56 {
+		t.Errorf("Got palette bytes %x %x %x, expected 12 34 56", cgb.bg_palettes[0x3E], cgb.bg_palettes[0x3F], cgb.bg_palettes[0x00])
+	}
+
+	// without auto-increment, the index stays
+	Write(OCPS, 0x05)
+	Write(OCPD, 0xAB)
+	Write(OCPD, 0xCD)
+	if Read(OCPS) != 0x45 || Read(OCPD) != 0xCD {
+		t.Errorf("Got OCPS %x, OCPD %x, expected 45 CD", Read(OCPS), Read(OCPD))
+	}
+}
+
+func TestBGAttributes(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+
+	// tile 1 is color 1 in bank 1 and color 2 in bank 0
+	Write(VBK, 1)
+	Write(0x8010, 0xFF)
+	Write(0x8011, 0x00)
+	Write(VBK, 0)
+	Write(0x8010, 0x00)
+	Write(0x8011, 0xFF)
+	// tile 2 only has its leftmost pixel set, in its last row
+	Write(0x802E, 0x80)
+
+	Write(0x9800, 1)
+	Write(0x9801, 2)
+	Write(VBK, 1)
+	Write(0x9800, 0x0B) // palette 3, bank 1
+	Write(0x9801, 0x60) // x and y flip
+	Write(VBK, 0)
+
+	// color 1 of palette 3, color 0 and 1 of palette 0
+	Write(BCPS, 0x80|(3*8+2))
+	Write(BCPD, 0x1F)
+	Write(BCPD, 0x00)
+	Write(BCPS, 0x80)
+	Write(BCPD, 0xE0)
+	Write(BCPD, 0x03)
+	Write(BCPD, 0x00)
+	Write(BCPD, 0x7C)
+
+	emu.ppu.tilemap = 0x9800
+	emu.ppu.tiledata = 0x8000
+	emu.ppu.scanline = 0
+	emu.ppu.RenderBG(0)
+
+	tests := []struct {
+		x        int
+		expected uint16
+	}{
+		{0, 0x001F}, // red from palette 3
+		{7, 0x001F},
+		{8, 0x03E0}, // flipped, so the pixel set in the last row is at the right in the first one
+		{15, 0x7C00},
+	}
+	for _, test := range tests {
+		if framebuffer[test.x] != test.expected {
+			t.Errorf("Pixel %d: %.4X; expected: %.4X", test.x, framebuffer[test.x], test.expected)
+		}
+	}
+}
+
+func TestCompatPalette(t *testing.T) {
+	emu := loadCgbRom(t, 0x00, ModelCGB)
+	if cgb.enabled || !cgb.compat {
+		t.Fatalf("Got CGB mode %t, compatibility mode %t, expected false true", cgb.enabled, cgb.compat)
+	}
+
+	// banking is not available to DMG games
+	Write(VBK, 1)
+	Write(0x8010, 0x00)
+	Write(0x8011, 0xFF)
+	Write(0x9800, 1)
+	Write(BGP, 0xE4)
+
+	emu.ppu.tilemap = 0x9800
+	emu.ppu.tiledata = 0x8000
+	emu.ppu.scanline = 0
+	emu.ppu.RenderBG(0)
+
+	expected := rgb555(compatPalettes.bg[2])
+	if framebuffer[0] != expected {
+		t.Errorf("Pixel 0: %.4X; expected: %.4X", framebuffer[0], expected)
+	}
+}
@@ -0,0 +1,200 @@
+package maybego
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Names of the keys or controller buttons bound to each joypad input.
+// Key names are the ones used by Fyne, e.g. "Z", "Return", "Up" or "LeftShift",
+// controller buttons use the SDL GameController names, e.g. "a", "back" or "dpup".
+// Every joypad input can have any number of them.
+type KeyBindings struct {
+	A      []string `toml:"a"`
+	B      []string `toml:"b"`
+	Select []string `toml:"select"`
+	Start  []string `toml:"start"`
+	Up     []string `toml:"up"`
+	Down   []string `toml:"down"`
+	Left   []string `toml:"left"`
+	Right  []string `toml:"right"`
+	TurboA []string `toml:"turbo_a"`
+	TurboB []string `toml:"turbo_b"`
+}
+
+type GamepadConfig struct {
+	Buttons KeyBindings `toml:"buttons"`
+	// the left stick acts as D-pad once it is pushed further than this, 0 disables it
+	StickDeadzone int `toml:"stick_deadzone"`
+}
+
+type Config struct {
+	// frames a turbo button stays pressed, then released
+	TurboRate uint          `toml:"turbo_rate"`
+	Keys      KeyBindings   `toml:"keys"`
+	Gamepad   GamepadConfig `toml:"gamepad"`
+	// key or controller button name to a macro, see ParseMacro
+	Macros map[string]string `toml:"macros"`
+	// where the Game Boy Printer writes its prints
+	PrinterDir string `toml:"printer_dir"`
+}
+
+// Resolves key names to the joypad input they are bound to.
+type keyMap struct {
+	buttons    map[string]Button
+	directions map[string]Direction
+	turbo      map[string]Button
+	macros     map[string]Macro
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		TurboRate:  DefaultTurboRate,
+		PrinterDir: "prints",
+		Keys: KeyBindings{
+			A:      []string{"V"},
+			B:      []string{"C"},
+			Select: []string{"X"},
+			Start:  []string{"Z"},
+			Up:     []string{"Up"},
+			Down:   []string{"Down"},
+			Left:   []string{"Left"},
+			Right:  []string{"Right"},
+			TurboA: []string{"F"},
+			TurboB: []string{"D"},
+		},
+		Gamepad: GamepadConfig{
+			Buttons: KeyBindings{
+				A:      []string{"a"},
+				B:      []string{"b"},
+				Select: []string{"back"},
+				Start:  []string{"start"},
+				Up:     []string{"dpup"},
+				Down:   []string{"dpdown"},
+				Left:   []string{"dpleft"},
+				Right:  []string{"dpright"},
+			},
+			StickDeadzone: 8000,
+		},
+	}
+}
+
+// Returns the path of config.toml in the user's config directory,
+// e.g. ~/.config/maybego/config.toml on Linux.
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "maybego", "config.toml"), nil
+}
+
+// Reads a TOML config. Settings missing from the file keep their defaults,
+// and a missing file gives the default config.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+	_, err := toml.DecodeFile(path, config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	return config, err
+}
+
+func (bindings *KeyBindings) keyMap() keyMap {
+	keys := keyMap{buttons: map[string]Button{}, directions: map[string]Direction{}, turbo: map[string]Button{}}
+	bind_buttons := func(names []string, b Button) {
+		for _, name := range names {
+			keys.buttons[name] = b
+		}
+	}
+	bind_directions := func(names []string, d Direction) {
+		for _, name := range names {
+			keys.directions[name] = d
+		}
+	}
+
+	bind_buttons(bindings.A, ButtonA)
+	bind_buttons(bindings.B, ButtonB)
+	bind_buttons(bindings.Select, ButtonSelect)
+	bind_buttons(bindings.Start, ButtonStart)
+	bind_directions(bindings.Up, DirectionUp)
+	bind_directions(bindings.Down, DirectionDown)
+	bind_directions(bindings.Left, DirectionLeft)
+	bind_directions(bindings.Right, DirectionRight)
+	for _, name := range bindings.TurboA {
+		keys.turbo[name] = ButtonA
+	}
+	for _, name := range bindings.TurboB {
+		keys.turbo[name] = ButtonB
+	}
+
+	return keys
+}
+
+// Parses every macro of the config. Macros that do not parse are left out,
+// the others are returned together with the errors of the bad ones.
+func (config *Config) macros() (map[string]Macro, error) {
+	macros := map[string]Macro{}
+	var errs []error
+	var names []string
+	for name := range config.Macros {
+		names = append(names, name)
+	}
+	// in a fixed order, so the errors are too
+	slices.Sort(names)
+	for _, name := range names {
+		macro, err := ParseMacro(config.Macros[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("macro for %s: %w", name, err))
+			continue
+		}
+		macros[name] = macro
+	}
+	return macros, errors.Join(errs...)
+}
+
+// Returns false if the key is not bound to anything.
+func (keys keyMap) press(emu *Emulator, name string) bool {
+	if b, ok := keys.buttons[name]; ok {
+		emu.PressButton(b)
+		return true
+	}
+	if d, ok := keys.directions[name]; ok {
+		emu.PressDirection(d)
+		return true
+	}
+	if b, ok := keys.turbo[name]; ok {
+		emu.PressTurbo(b)
+		return true
+	}
+	if macro, ok := keys.macros[name]; ok {
+		emu.PlayMacro(macro)
+		return true
+	}
+	return false
+}
+
+// Returns false if the key is not bound to anything.
+func (keys keyMap) release(emu *Emulator, name string) bool {
+	if b, ok := keys.buttons[name]; ok {
+		emu.ReleaseButton(b)
+		return true
+	}
+	if d, ok := keys.directions[name]; ok {
+		emu.ReleaseDirection(d)
+		return true
+	}
+	if b, ok := keys.turbo[name]; ok {
+		emu.ReleaseTurbo(b)
+		return true
+	}
+	// macros run until they are done
+	_, ok := keys.macros[name]
+	return ok
+}
@@ -0,0 +1,54 @@
+package maybego
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[keys]\nstart = [\"Z\", \"Return\"]\n"), 0666)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(config.Keys.Start, []string{"Z", "Return"}) {
+		t.Errorf("Got start keys %v, expected [Z Return]", config.Keys.Start)
+	}
+	if !slices.Equal(config.Keys.A, DefaultConfig().Keys.A) {
+		t.Errorf("Got A keys %v, expected the default %v", config.Keys.A, DefaultConfig().Keys.A)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(config.Keys.Up, DefaultConfig().Keys.Up) {
+		t.Errorf("Got up keys %v, expected the default %v", config.Keys.Up, DefaultConfig().Keys.Up)
+	}
+}
+
+func TestKeyMap(t *testing.T) {
+	emu := NewEmulator(logger)
+	config := DefaultConfig()
+	config.Keys.Start = []string{"Z", "Return"}
+	keys := config.Keys.keyMap()
+
+	keys.press(emu, "Return")
+	if emu.joypad.buttons != 0x7 {
+		t.Errorf("Current buttons: %x; expected: %x", emu.joypad.buttons, 0x7)
+	}
+	keys.release(emu, "Return")
+	keys.press(emu, "Up")
+	if emu.joypad.buttons != 0xF || emu.joypad.directions != 0xB {
+		t.Errorf("Current buttons: %x directions: %x; expected: %x %x", emu.joypad.buttons, emu.joypad.directions, 0xF, 0xB)
+	}
+	if keys.press(emu, "Q") {
+		t.Error("Unbound key Q was handled")
+	}
+}
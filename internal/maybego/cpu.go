@@ -53,6 +53,11 @@ type CPU struct {
 	cbOps         [256]func() byte
 	interrupts    [5]byte
 
+	// called on LD B,B, which test ROMs use as a software breakpoint
+	debugBreakpoint func()
+	// shadow call stack for the debugger
+	calls []CallFrame
+
 	// logging
 	logger *Logger
 }
@@ -205,10 +210,19 @@ func NewCPU(logger *Logger) *CPU {
 	return cpu
 }
 
-func (cpu *CPU) Fetch() {
+// Sets a function that is called whenever LD B,B is executed.
+// Passing nil removes it again.
+func (cpu *CPU) SetDebugBreakpoint(hook func()) {
+	cpu.debugBreakpoint = hook
+}
+
+// Reads the opcode at PC. If an interrupt is dispatched instead, returns its cycles
+// and the handler starts with the next Fetch.
+func (cpu *CPU) Fetch() byte {
+	dispatch := byte(0)
 	if cpu.flg.IME || cpu.flg.HALT {
 		// fmt.Println("entering interrupt handling")
-		cpu.interrupt()
+		dispatch = cpu.interrupt()
 	}
 
 	if cpu.pendingIME {
@@ -217,13 +231,14 @@ func (cpu *CPU) Fetch() {
 		cpu.flg.IME = true
 	}
 
-	if cpu.flg.HALT {
-		return
+	if cpu.flg.HALT || dispatch != 0 {
+		return dispatch
 	}
 	cpu.currentOpcode = Read(cpu.reg.PC)
 	cpu.logger.LogRegisters(cpu.reg.A, cpu.reg.B, cpu.reg.C, cpu.reg.D, cpu.reg.E, cpu.reg.H, cpu.reg.L, cpu.reg.SP)
 	cpu.logger.LogFlags(cpu.flg.Z, cpu.flg.C, cpu.flg.N, cpu.flg.H, cpu.flg.HALT, cpu.flg.IME)
 	cpu.logger.LogPC(cpu.reg.PC, cpu.clk.cycles, byte(Read(0xFF41)&0x3), cpu.currentOpcode, Read(cpu.reg.PC+1), Read(cpu.reg.PC+2))
+	return 0
 }
 
 func (cpu *CPU) Decode() byte {
@@ -235,6 +250,7 @@ func (cpu *CPU) Decode() byte {
 	// 	}
 	// }
 	if cpu.flg.HALT { // && !interrupt_occurred {
+		cpu.clk.cycles++ // the clock keeps running while halted
 		return 1
 	}
 	cycles := cpu.opcodes[cpu.currentOpcode]()
@@ -503,7 +519,9 @@ func (cpu *CPU) call(flag bool) byte {
 		lo := byte(cpu.reg.PC + 3)
 		hi := byte((cpu.reg.PC + 3) >> 8)
 		cpu.push16(lo, hi)
-		cpu.reg.PC = uint16(Read(cpu.reg.PC+1)) + (uint16(Read(cpu.reg.PC+2)) << 8)
+		target := uint16(Read(cpu.reg.PC+1)) + (uint16(Read(cpu.reg.PC+2)) << 8)
+		cpu.pushCall(cpu.reg.PC, target, false)
+		cpu.reg.PC = target
 		return 6
 	}
 	cpu.reg.PC += 3
@@ -513,6 +531,7 @@ func (cpu *CPU) call(flag bool) byte {
 func (cpu *CPU) ret(flag bool) byte {
 	// return if flag is true, otherwise continue to next instruction
 	if flag {
+		cpu.popCalls()
 		cpu.pop16reg(&cpu.reg.PC)
 		return 5
 	}
@@ -525,6 +544,8 @@ func (cpu *CPU) rst(vec byte, advance_PC bool) byte {
 	lo := byte(saved_pc)
 	hi := byte((saved_pc) >> 8)
 	cpu.push16(lo, hi)
+	// interrupts are dispatched through here without advancing the PC
+	cpu.pushCall(cpu.reg.PC, uint16(vec), !advance_PC)
 	cpu.reg.PC = uint16(vec)
 	return 4
 }
@@ -693,7 +714,8 @@ func (cpu *CPU) cpu0F() byte { // RRCA
 	return 1
 }
 
-func (cpu *CPU) cpu10() byte { // TODO: STOP
+func (cpu *CPU) cpu10() byte { // TODO: STOP, only the CGB speed switch for now
+	switchSpeed()
 	cpu.reg.PC += 2
 	return 1
 }
@@ -1032,6 +1054,9 @@ func (cpu *CPU) cpu3F() byte { // CCF
 func (cpu *CPU) cpu40() byte { // LD B,B
 	cpu.ld8(&cpu.reg.B, cpu.reg.B)
 	cpu.reg.PC++
+	if cpu.debugBreakpoint != nil {
+		cpu.debugBreakpoint()
+	}
 	return 1
 }
 
@@ -3779,10 +3804,10 @@ func RequestInterrupt(bit byte) {
 	Write(IF, prev|(1<<bit))
 }
 
-func (cpu *CPU) interrupt() byte { // handle interrupts
+func (cpu *CPU) interrupt() byte { // handle interrupts, returns 0 if none was dispatched
 	// check if interrupt occurred
 	// loop through every bit in the interrupt flag register until we find one
-	cycles := byte(20 + FlagToBit(cpu.flg.HALT)*4)
+	cycles := byte(0)
 	// fmt.Printf("cycles: %d\n", cycles)
 	for i := byte(0); i < 5; i++ {
 		check_bit := byte(0x01 << i)
@@ -3808,8 +3833,16 @@ func (cpu *CPU) interrupt() byte { // handle interrupts
 			// and pushing the current PC to stack already
 			// so I won't write the same code here
 			// fmt.Printf("Before cpu.rst at cycles %d, jumping to %d\n", cpu.clk.cycles, cpu.interrupts[int(i)])
+			// the pushes hit watchpoints like those of a CALL at the PC the interrupt came in at
+			if busWatch != nil {
+				busWatch.pc, busWatch.cpu = cpu.reg.PC, true
+			}
 			cpu.rst(cpu.interrupts[int(i)], false)
+			if busWatch != nil {
+				busWatch.cpu = false
+			}
 			cpu.flg.IME = false
+			cycles = 20 + FlagToBit(cpu.flg.HALT)*4
 
 		} else if cpu.flg.HALT {
 			cpu.flg.HALT = false
@@ -3896,6 +3929,29 @@ func (cpu *CPU) Reset() {
 	cpu.reg.E = 0xD8 // after boot: 0xD8
 	cpu.reg.H = 0x01 // after boot: 0x01
 	cpu.reg.L = 0x4D // after boot: 0x4D
+	cpu.calls = nil
+
+	if cgbHardware() {
+		// the CGB boot rom leaves different values, A tells games they run on a CGB
+		cpu.reg.A = 0x11
+		cpu.flg.Z = true
+		cpu.flg.H = false
+		cpu.flg.C = false
+		cpu.reg.C = 0x00
+		cpu.reg.D = 0xFF
+		cpu.reg.E = 0x56
+		cpu.reg.H = 0x00
+		cpu.reg.L = 0x0D
+	}
+	if sgb.enabled {
+		cpu.flg.Z = false
+		cpu.flg.H = false
+		cpu.flg.C = false
+		cpu.reg.C = 0x14
+		cpu.reg.E = 0x00
+		cpu.reg.H = 0xC0
+		cpu.reg.L = 0x60
+	}
 
 	cpu.clk.cycles = 0
 }
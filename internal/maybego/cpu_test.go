@@ -507,14 +507,19 @@ func TestLDToAdr(t *testing.T) { // {{{
 	}) // }}}
 
 	t.Run("LD (FF00+u8), A", func(t *testing.T) {
+		// JOYP writes go to the joypad, which keeps only the select bits
+		prev_joypad := busJoypad
+		t.Cleanup(func() { busJoypad = prev_joypad })
+		busJoypad = NewJoypad()
 		offset_tests := []struct {
-			u8 uint16
-			a  byte
+			u8       uint16
+			a        byte
+			expected byte
 		}{
-			{0x00, 0xDE},
-			{0xFF, 0xAD},
-			{0x08, 0xBE},
-			{0x80, 0xA7},
+			{0x00, 0xDE, 0xDF},
+			{0xFF, 0xAD, 0xAD},
+			{0x08, 0xBE, 0xBE},
+			{0x80, 0xA7, 0xA7},
 		}
 
 		for _, test := range offset_tests {
@@ -530,8 +535,8 @@ func TestLDToAdr(t *testing.T) { // {{{
 				actual_pc := cpu.reg.PC
 				actual_byte := Read(adress)
 
-				if actual_byte != test.a {
-					t.Errorf("Current byte at %x: %x, expected: %x", adress, actual_byte, test.a)
+				if actual_byte != test.expected {
+					t.Errorf("Current byte at %x: %x, expected: %x", adress, actual_byte, test.expected)
 				}
 				if actual_cycles != expected_cycles {
 					t.Errorf("Got %d cycles, expected %d", actual_cycles, expected_cycles)
@@ -551,8 +556,8 @@ func TestLDToAdr(t *testing.T) { // {{{
 				actual_pc := cpu.reg.PC
 				actual_byte := Read(adress)
 
-				if actual_byte != test.a {
-					t.Errorf("Current byte at %x: %x, expected: %x", adress, actual_byte, test.a)
+				if actual_byte != test.expected {
+					t.Errorf("Current byte at %x: %x, expected: %x", adress, actual_byte, test.expected)
 				}
 				if actual_cycles != expected_cycles {
 					t.Errorf("Got %d cycles, expected %d", actual_cycles, expected_cycles)
@@ -1671,6 +1676,24 @@ func TestCpu3F(t *testing.T) {
 
 }
 
+func TestCpu40DebugBreakpoint(t *testing.T) {
+	hits := 0
+	cpu.SetDebugBreakpoint(func() { hits++ })
+	defer cpu.SetDebugBreakpoint(nil)
+
+	cpu.reg.PC = 0x1234
+	cpu.reg.B = 0x42
+	cpu.cpu40()
+	cpu.cpu41() // LD B,C must not trigger it
+
+	if hits != 1 {
+		t.Errorf("Debug breakpoint hit %d times, expected 1", hits)
+	}
+	if cpu.reg.PC != 0x1236 {
+		t.Errorf("Current PC: %x, expected: %x", cpu.reg.PC, 0x1236)
+	}
+}
+
 func TestCpuC3(t *testing.T) {
 	var tests = []struct {
 		pc       uint16
@@ -0,0 +1,331 @@
+package maybego
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Halts before the instruction at Address runs, if the condition holds.
+// A tracepoint logs its message instead and lets the emulator continue.
+type Breakpoint struct {
+	Address uint16
+	// nil always holds
+	Condition *Expr
+	// how often the condition held, and how many of those to let pass
+	Hits   uint
+	Ignore uint
+	// {expr} in the message of a tracepoint is replaced by the value in hex
+	Trace   bool
+	Message string
+	// a disabled breakpoint is kept but never checked
+	Disabled bool
+	Label    string
+	texts    []string
+	values   []*Expr
+}
+
+// The breakpoints of an emulator.
+type Debugger struct {
+	emu         *Emulator
+	breakpoints []*Breakpoint
+	trace       io.Writer
+	// instruction count at the last check
+	checked uint
+	symbols *Symbols
+	// while stepping over or out, halts once the call stack is at most step_depth deep
+	stepping   bool
+	step_depth int
+	step_start uint
+}
+
+func NewDebugger(emu *Emulator) *Debugger {
+	return &Debugger{emu: emu, trace: os.Stdout, checked: ^uint(0)}
+}
+
+func (dbg *Debugger) SetSymbols(syms *Symbols) {
+	dbg.symbols = syms
+}
+
+// Names an address by its symbol, "" without one.
+func (dbg *Debugger) Symbol(adr uint16) string {
+	return dbg.symbols.Name(adr)
+}
+
+// Runs the instruction at PC and halts after it, or after the call it makes returns.
+// Interrupts dispatched on the way are stepped over as well.
+func (dbg *Debugger) StepOver() {
+	dbg.step(len(dbg.emu.CallStack()))
+}
+
+// Halts once the current call returned. Outside of any call it steps over.
+func (dbg *Debugger) StepOut() {
+	dbg.step(max(len(dbg.emu.CallStack())-1, 0))
+}
+
+func (dbg *Debugger) step(depth int) {
+	dbg.stepping, dbg.step_depth, dbg.step_start = true, depth, dbg.emu.instructions
+}
+
+// Tracepoints log to w, standard output by default.
+func (dbg *Debugger) SetTraceOutput(w io.Writer) {
+	dbg.trace = w
+}
+
+func (dbg *Debugger) Breakpoints() []*Breakpoint {
+	return dbg.breakpoints
+}
+
+// Adds a breakpoint that halts if the condition holds, an empty condition always holds.
+func (dbg *Debugger) AddBreakpoint(address uint16, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{Address: address}
+	if strings.TrimSpace(condition) != "" {
+		expr, err := ParseExpr(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.Condition = expr
+	}
+	dbg.breakpoints = append(dbg.breakpoints, bp)
+	return bp, nil
+}
+
+// Adds a tracepoint that logs the message if the condition holds, e.g. "A={A} [HL]={[HL]}".
+func (dbg *Debugger) AddTracepoint(address uint16, condition string, message string) (*Breakpoint, error) {
+	texts, values, err := parseTraceMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := dbg.AddBreakpoint(address, condition)
+	if err != nil {
+		return nil, err
+	}
+	bp.Trace, bp.Message, bp.texts, bp.values = true, message, texts, values
+	return bp, nil
+}
+
+// Returns the first breakpoint or tracepoint at the address, or nil.
+func (dbg *Debugger) BreakpointAt(address uint16) *Breakpoint {
+	for _, bp := range dbg.breakpoints {
+		if bp.Address == address {
+			return bp
+		}
+	}
+	return nil
+}
+
+// Adds an unconditional breakpoint at the address, or removes the breakpoints
+// already there. Returns the added breakpoint, nil if they were removed.
+func (dbg *Debugger) ToggleBreakpoint(address uint16) *Breakpoint {
+	if dbg.BreakpointAt(address) == nil {
+		bp, _ := dbg.AddBreakpoint(address, "")
+		return bp
+	}
+	for bp := dbg.BreakpointAt(address); bp != nil; bp = dbg.BreakpointAt(address) {
+		dbg.RemoveBreakpoint(bp)
+	}
+	return nil
+}
+
+func (dbg *Debugger) RemoveBreakpoint(bp *Breakpoint) {
+	for i, other := range dbg.breakpoints {
+		if other == bp {
+			dbg.breakpoints = append(dbg.breakpoints[:i], dbg.breakpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// Called before the instruction at PC runs. Counts the hits of its breakpoints,
+// logs its tracepoints and returns true if a breakpoint or a step over or out halts the emulator.
+// Cycles of the same instruction, e.g. while the CPU is halted, are only checked once.
+func (dbg *Debugger) Check() bool {
+	if dbg.emu.instructions == dbg.checked {
+		return false
+	}
+	dbg.checked = dbg.emu.instructions
+
+	pc := dbg.emu.cpu.reg.PC
+	halt := false
+	for _, bp := range dbg.breakpoints {
+		if bp.Disabled || bp.Address != pc || (bp.Condition != nil && bp.Condition.Eval(dbg.emu) == 0) {
+			continue
+		}
+		bp.Hits++
+		if bp.Hits <= bp.Ignore {
+			continue
+		}
+		if bp.Trace {
+			fmt.Fprintln(dbg.trace, bp.traceMessage(dbg.emu))
+			continue
+		}
+		halt = true
+	}
+
+	if dbg.stepping && dbg.emu.instructions != dbg.step_start && len(dbg.emu.CallStack()) <= dbg.step_depth {
+		halt = true
+	}
+	if halt {
+		dbg.stepping = false
+	}
+	return halt
+}
+
+// Splits a message into the texts around {expr} and the expressions.
+func parseTraceMessage(message string) ([]string, []*Expr, error) {
+	var texts []string
+	var values []*Expr
+	for {
+		start := strings.Index(message, "{")
+		if start < 0 {
+			return append(texts, message), values, nil
+		}
+		end := strings.Index(message[start:], "}")
+		if end < 0 {
+			return nil, nil, fmt.Errorf("missing } in %q", message)
+		}
+		expr, err := ParseExpr(message[start+1 : start+end])
+		if err != nil {
+			return nil, nil, err
+		}
+		texts = append(texts, message[:start])
+		values = append(values, expr)
+		message = message[start+end+1:]
+	}
+}
+
+func (bp *Breakpoint) traceMessage(emu *Emulator) string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "%04X: ", bp.Address)
+	for i, value := range bp.values {
+		message.WriteString(bp.texts[i])
+		if v := value.Eval(emu); v >= 0 && v <= 0xFF {
+			fmt.Fprintf(&message, "%02X", v)
+		} else {
+			fmt.Fprintf(&message, "%04X", v)
+		}
+	}
+	message.WriteString(bp.texts[len(bp.texts)-1])
+	return message.String()
+}
+
+func (bp *Breakpoint) String() string {
+	text := fmt.Sprintf("%04X", bp.Address)
+	if bp.Label != "" {
+		text += " " + bp.Label
+	}
+	if bp.Condition != nil {
+		text += " if " + bp.Condition.String()
+	}
+	if bp.Trace {
+		text += fmt.Sprintf(" log %q", bp.Message)
+	}
+	if bp.Disabled {
+		text += " disabled"
+	}
+	return text + fmt.Sprintf(" (%d hits)", bp.Hits)
+}
+
+// A breakpoint as stored in a breakpoint file, without its hits.
+type savedBreakpoint struct {
+	Address   string `toml:"address"`
+	Label     string `toml:"label,omitempty"`
+	Condition string `toml:"condition,omitempty"`
+	Ignore    uint   `toml:"ignore,omitempty"`
+	Trace     bool   `toml:"trace,omitempty"`
+	Message   string `toml:"message,omitempty"`
+	Disabled  bool   `toml:"disabled,omitempty"`
+}
+
+type breakpointFile struct {
+	Breakpoints []savedBreakpoint `toml:"breakpoint"`
+}
+
+// Breakpoints of a ROM are stored next to it as <rom>.breakpoints.
+func BreakpointPath(rom_path string) string {
+	return rom_path + ".breakpoints"
+}
+
+// Writes the breakpoints and tracepoints as TOML.
+func (dbg *Debugger) SaveBreakpoints(w io.Writer) error {
+	var file breakpointFile
+	for _, bp := range dbg.breakpoints {
+		saved := savedBreakpoint{Address: fmt.Sprintf("%04X", bp.Address), Label: bp.Label, Ignore: bp.Ignore,
+			Trace: bp.Trace, Message: bp.Message, Disabled: bp.Disabled}
+		if bp.Condition != nil {
+			saved.Condition = bp.Condition.String()
+		}
+		file.Breakpoints = append(file.Breakpoints, saved)
+	}
+	return toml.NewEncoder(w).Encode(file)
+}
+
+// Replaces the breakpoints with the ones written by SaveBreakpoints.
+func (dbg *Debugger) LoadBreakpoints(r io.Reader) error {
+	var file breakpointFile
+	if _, err := toml.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+
+	loaded := NewDebugger(dbg.emu)
+	for _, saved := range file.Breakpoints {
+		adr, err := parseHex(saved.Address, 16)
+		if err != nil {
+			return err
+		}
+		var bp *Breakpoint
+		if saved.Trace {
+			bp, err = loaded.AddTracepoint(uint16(adr), saved.Condition, saved.Message)
+		} else {
+			bp, err = loaded.AddBreakpoint(uint16(adr), saved.Condition)
+		}
+		if err != nil {
+			return fmt.Errorf("breakpoint at %s: %w", saved.Address, err)
+		}
+		bp.Label, bp.Ignore, bp.Disabled = saved.Label, saved.Ignore, saved.Disabled
+	}
+	dbg.breakpoints = loaded.breakpoints
+	return nil
+}
+
+// Writes the breakpoints to a file, or removes the file if there are none.
+func (dbg *Debugger) SaveBreakpointFile(path string) error {
+	if len(dbg.breakpoints) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return dbg.SaveBreakpoints(file)
+}
+
+// Loads the breakpoints from a file, a missing file gives no breakpoints.
+func (dbg *Debugger) LoadBreakpointFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		dbg.breakpoints = nil
+		return nil
+	}
+	if err != nil {
+		dbg.breakpoints = nil
+		return err
+	}
+	defer file.Close()
+	// the breakpoints of the previous ROM do not belong to this one
+	if err := dbg.LoadBreakpoints(file); err != nil {
+		dbg.breakpoints = nil
+		return err
+	}
+	return nil
+}
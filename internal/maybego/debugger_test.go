@@ -0,0 +1,256 @@
+package maybego
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Counts B down from 3 in a loop at 0x100: DEC B; JR NZ,-3; HALT
+func loadLoopProgram() *Emulator {
+	emu := loadProgram(programRom(0x05, 0x20, 0xFD, 0x76), ModelAuto)
+	emu.cpu.reg.B = 3
+	return emu
+}
+
+// Runs until a breakpoint halts, returns the PCs it halted at.
+func runDebugger(dbg *Debugger, steps int) []uint16 {
+	var halts []uint16
+	for range steps {
+		if dbg.Check() {
+			halts = append(halts, dbg.emu.cpu.reg.PC)
+		}
+		dbg.emu.Run()
+	}
+	return halts
+}
+
+func TestBreakpointConditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		ignore    uint
+		expected  []uint16
+		hits      uint
+	}{
+		{"always", "", 0, []uint16{0x100, 0x100, 0x100}, 3},
+		{"condition", "B == 2", 0, []uint16{0x100}, 1},
+		{"ignore", "", 2, []uint16{0x100}, 3},
+		{"never", "B > 3", 0, nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbg := NewDebugger(loadLoopProgram())
+			bp, err := dbg.AddBreakpoint(0x100, test.condition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bp.Ignore = test.ignore
+
+			halts := runDebugger(dbg, 20)
+			if !slices.Equal(halts, test.expected) {
+				t.Errorf("Halted at %x; expected: %x", halts, test.expected)
+			}
+			if bp.Hits != test.hits {
+				t.Errorf("Current hits: %d; expected: %d", bp.Hits, test.hits)
+			}
+		})
+	}
+}
+
+func TestTracepoint(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	var out bytes.Buffer
+	dbg.SetTraceOutput(&out)
+	if _, err := dbg.AddTracepoint(0x100, "B != 2", "B={B} BC={BC}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if halts := runDebugger(dbg, 20); len(halts) != 0 {
+		t.Errorf("Tracepoint halted at %x", halts)
+	}
+	expected := "0100: B=03 BC=0313\n0100: B=01 BC=0113\n"
+	if out.String() != expected {
+		t.Errorf("Current log: %q; expected: %q", out.String(), expected)
+	}
+}
+
+func TestBreakpointHaltedOnce(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	dbg.AddBreakpoint(0x104, "")
+
+	// the CPU stays at the instruction after HALT
+	if halts := runDebugger(dbg, 30); len(halts) != 1 {
+		t.Errorf("Halted at %x; expected once at 104", halts)
+	}
+}
+
+// The dispatch of an interrupt is a step of its own, so a breakpoint on the vector halts
+// before the first instruction of the handler.
+func TestBreakpointOnInterruptVector(t *testing.T) {
+	emu := loadLoopProgram()
+	// NOP; RETI at the VBlank vector
+	DebugWrite(0x40, 0x00)
+	DebugWrite(0x41, 0xD9)
+	Write(IE, 0x01)
+	Write(IF, 0x01)
+	emu.cpu.flg.IME = true
+
+	dbg := NewDebugger(emu)
+	dbg.AddBreakpoint(0x40, "")
+	halts := runDebugger(dbg, 4)
+	if !slices.Equal(halts, []uint16{0x40}) {
+		t.Errorf("Halted at %x; expected once at 40", halts)
+	}
+}
+
+func TestTraceMessageErrors(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	for _, message := range []string{"A={A", "A={Q}"} {
+		if _, err := dbg.AddTracepoint(0x100, "", message); err == nil {
+			t.Errorf("%q accepted, expected an error", message)
+		}
+	}
+	if len(dbg.Breakpoints()) != 0 {
+		t.Errorf("Got %d breakpoints, expected none", len(dbg.Breakpoints()))
+	}
+}
+
+func TestToggleBreakpoint(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	if bp := dbg.ToggleBreakpoint(0x101); bp == nil || bp.Address != 0x101 {
+		t.Errorf("Current breakpoint: %v; expected one at 101", bp)
+	}
+	dbg.AddTracepoint(0x101, "", "B={B}")
+	if bp := dbg.ToggleBreakpoint(0x101); bp != nil {
+		t.Errorf("Current breakpoint: %v; expected: nil", bp)
+	}
+	if len(dbg.Breakpoints()) != 0 {
+		t.Errorf("Got %d breakpoints after toggling twice, expected none", len(dbg.Breakpoints()))
+	}
+}
+
+func TestDisabledBreakpoint(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	bp, _ := dbg.AddBreakpoint(0x100, "")
+	bp.Disabled = true
+
+	if halts := runDebugger(dbg, 20); len(halts) != 0 {
+		t.Errorf("Disabled breakpoint halted at %x", halts)
+	}
+	if bp.Hits != 0 {
+		t.Errorf("Current hits: %d; expected: 0", bp.Hits)
+	}
+}
+
+func TestSaveBreakpoints(t *testing.T) {
+	dbg := NewDebugger(loadLoopProgram())
+	bp, _ := dbg.AddBreakpoint(0x100, "B == 2")
+	bp.Label, bp.Ignore, bp.Hits = "loop", 1, 5
+	trace, _ := dbg.AddTracepoint(0x103, "", "A={A}")
+	trace.Disabled = true
+
+	path := BreakpointPath(filepath.Join(t.TempDir(), "game.gb"))
+	if err := dbg.SaveBreakpointFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewDebugger(dbg.emu)
+	if err := loaded.LoadBreakpointFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"0100 loop if B == 2 (0 hits)", "0103 log \"A={A}\" disabled (0 hits)"}
+	var current []string
+	for _, bp := range loaded.Breakpoints() {
+		current = append(current, bp.String())
+	}
+	if !slices.Equal(current, expected) {
+		t.Errorf("Current breakpoints: %q; expected: %q", current, expected)
+	}
+	if loaded.Breakpoints()[0].Ignore != 1 {
+		t.Errorf("Current ignore count: %d; expected: 1", loaded.Breakpoints()[0].Ignore)
+	}
+
+	// without breakpoints the file is removed, and a missing file loads none
+	loaded.RemoveBreakpoint(loaded.Breakpoints()[1])
+	loaded.RemoveBreakpoint(loaded.Breakpoints()[0])
+	if err := loaded.SaveBreakpointFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbg.LoadBreakpointFile(path); err != nil || len(dbg.Breakpoints()) != 0 {
+		t.Errorf("Got %d breakpoints, %v from a removed file; expected none", len(dbg.Breakpoints()), err)
+	}
+
+	// a broken file loads none either, instead of keeping the previous ones
+	dbg.AddBreakpoint(0x100, "")
+	os.WriteFile(path, []byte("[[breakpoint\n"), 0o644)
+	if err := dbg.LoadBreakpointFile(path); err == nil || len(dbg.Breakpoints()) != 0 {
+		t.Errorf("Got %d breakpoints, %v from a broken file; expected none and an error", len(dbg.Breakpoints()), err)
+	}
+}
+
+// Runs until the debugger halts, returns the PC it halted at.
+func runUntilHalt(t *testing.T, dbg *Debugger) uint16 {
+	t.Helper()
+	for range 1000 {
+		if dbg.Check() {
+			return dbg.emu.cpu.reg.PC
+		}
+		dbg.emu.Run()
+	}
+	t.Fatalf("Debugger did not halt, PC: %x", dbg.emu.cpu.reg.PC)
+	return 0
+}
+
+func TestStepOverAndOut(t *testing.T) {
+	dbg := NewDebugger(loadCallProgram())
+	dbg.AddBreakpoint(0x120, "")
+	if pc := runUntilHalt(t, dbg); pc != 0x120 {
+		t.Fatalf("Halted at %x; expected: 120", pc)
+	}
+
+	steps := []struct {
+		name     string
+		step     func()
+		expected uint16
+	}{
+		{"step out of 0120", dbg.StepOut, 0x113},
+		{"step out of 0110", dbg.StepOut, 0x103},
+		{"step over RST", dbg.StepOver, 0x104},
+	}
+	for _, step := range steps {
+		step.step()
+		if pc := runUntilHalt(t, dbg); pc != step.expected {
+			t.Errorf("%s: halted at %x; expected: %x", step.name, pc, step.expected)
+		}
+	}
+
+	dbg = NewDebugger(loadCallProgram())
+	dbg.StepOver()
+	if pc := runUntilHalt(t, dbg); pc != 0x103 {
+		t.Errorf("Step over CALL halted at %x; expected: 103", pc)
+	}
+	// outside of any call stepping out steps over the RST
+	dbg.StepOut()
+	if pc := runUntilHalt(t, dbg); pc != 0x104 {
+		t.Errorf("Step out at the top halted at %x; expected: 104", pc)
+	}
+}
+
+func TestStepOverStopsAtBreakpoint(t *testing.T) {
+	dbg := NewDebugger(loadCallProgram())
+	dbg.AddBreakpoint(0x121, "")
+	dbg.StepOver()
+	if pc := runUntilHalt(t, dbg); pc != 0x121 {
+		t.Errorf("Halted at %x; expected: 121", pc)
+	}
+	// the step over ended with the breakpoint
+	dbg.RemoveBreakpoint(dbg.Breakpoints()[0])
+	dbg.emu.Run()
+	if dbg.Check() {
+		t.Errorf("Halted at %x after the step over ended", dbg.emu.cpu.reg.PC)
+	}
+}
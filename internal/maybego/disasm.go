@@ -808,3 +808,67 @@ func (dis *Disasm) not_implemented() string {
 	dis.current_addr++
 	return "not implemented yet!"
 }
+
+// Returns the target of the jump, call or RST at the offset, if it has a fixed one.
+func (dis *Disasm) Target(offset uint) (uint16, bool) {
+	if dis.file == nil || offset >= uint(len(*dis.file)) {
+		return 0, false
+	}
+	file := *dis.file
+	opc := file[offset]
+	switch {
+	case opc == 0x18 || opc == 0x20 || opc == 0x28 || opc == 0x30 || opc == 0x38:
+		if offset+1 >= uint(len(file)) {
+			return 0, false
+		}
+		return uint16(int(offset) + 2 + int(int8(file[offset+1]))), true
+	case opc == 0xC3 || opc == 0xCD || (opc&0xE7 == 0xC2) || (opc&0xE7 == 0xC4):
+		if offset+2 >= uint(len(file)) {
+			return 0, false
+		}
+		return uint16(file[offset+2])<<8 | uint16(file[offset+1]), true
+	case opc&0xC7 == 0xC7:
+		return uint16(opc & 0x38), true
+	}
+	return 0, false
+}
+
+// How many places the disassembly remembers to go back to.
+const disasmHistorySize = 100
+
+// Places visited in the disassembly, to go back and forward like in a browser.
+type disasmHistory struct {
+	entries []uint16
+	current int
+}
+
+// Visiting a place drops the ones that could be gone forward to.
+func (history *disasmHistory) visit(adr uint16) {
+	if len(history.entries) > 0 && history.entries[history.current] == adr {
+		return
+	}
+	if len(history.entries) > 0 {
+		history.entries = history.entries[:history.current+1]
+	}
+	history.entries = append(history.entries, adr)
+	if len(history.entries) > disasmHistorySize {
+		history.entries = history.entries[1:]
+	}
+	history.current = len(history.entries) - 1
+}
+
+func (history *disasmHistory) back() (uint16, bool) {
+	if history.current == 0 {
+		return 0, false
+	}
+	history.current--
+	return history.entries[history.current], true
+}
+
+func (history *disasmHistory) forward() (uint16, bool) {
+	if history.current+1 >= len(history.entries) {
+		return 0, false
+	}
+	history.current++
+	return history.entries[history.current], true
+}
@@ -0,0 +1,84 @@
+package maybego
+
+import (
+	"testing"
+)
+
+func TestDisasmTarget(t *testing.T) {
+	rom := []byte{
+		0x18, 0xFE, // JR -2
+		0x20, 0x05, // JR NZ,+5
+		0xC3, 0x50, 0x01, // JP 0150
+		0xDA, 0x34, 0x12, // JP C,1234
+		0xCD, 0x00, 0x40, // CALL 4000
+		0xC4, 0xEF, 0xBE, // CALL NZ,BEEF
+		0xDF,       // RST 18
+		0xE9,       // JP (HL)
+		0x00,       // NOP
+		0xCD, 0x00, // CALL cut off
+	}
+	tests := []struct {
+		offset   uint
+		expected uint16
+		ok       bool
+	}{
+		{0x00, 0x0000, true},
+		{0x02, 0x0009, true},
+		{0x04, 0x0150, true},
+		{0x07, 0x1234, true},
+		{0x0A, 0x4000, true},
+		{0x0D, 0xBEEF, true},
+		{0x10, 0x0018, true},
+		{0x11, 0, false},
+		{0x12, 0, false},
+		{0x13, 0, false},
+		{0x20, 0, false},
+	}
+
+	disasm := NewDisasm()
+	disasm.SetFile(&rom)
+	for _, test := range tests {
+		target, ok := disasm.Target(test.offset)
+		if target != test.expected || ok != test.ok {
+			t.Errorf("Current target at %x: %x, %t; expected: %x, %t", test.offset, target, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestDisasmHistory(t *testing.T) {
+	var history disasmHistory
+	if _, ok := history.back(); ok {
+		t.Errorf("Went back in an empty history")
+	}
+	for _, adr := range []uint16{0x100, 0x150, 0x150, 0x2000} {
+		history.visit(adr)
+	}
+
+	steps := []struct {
+		name     string
+		step     func() (uint16, bool)
+		expected uint16
+		ok       bool
+	}{
+		{"back", history.back, 0x150, true},
+		{"back", history.back, 0x100, true},
+		{"back at the start", history.back, 0, false},
+		{"forward", history.forward, 0x150, true},
+		{"visit", func() (uint16, bool) { history.visit(0x3000); return 0x3000, true }, 0x3000, true},
+		{"forward after visit", history.forward, 0, false},
+		{"back after visit", history.back, 0x150, true},
+	}
+	for _, step := range steps {
+		adr, ok := step.step()
+		if adr != step.expected || ok != step.ok {
+			t.Errorf("%s: current %x, %t; expected: %x, %t", step.name, adr, ok, step.expected, step.ok)
+		}
+	}
+
+	for i := range disasmHistorySize + 10 {
+		history.visit(uint16(i))
+	}
+	if len(history.entries) != disasmHistorySize {
+		t.Errorf("Current history size: %d; expected: %d", len(history.entries), disasmHistorySize)
+	}
+}
@@ -1,11 +1,35 @@
 package maybego
 
+import "hash/crc32"
+
 type Emulator struct {
 	cpu        *CPU
 	ppu        *PPU
 	joypad     *Joypad
+	serial     *Serial
+	watch      *watchState
 	rom_loaded bool
 	logger     *Logger
+	// executed so far, halted cycles and DMA stalls do not count
+	instructions uint
+
+	rom          []byte
+	rom_checksum uint32
+	model        Model
+	movie        *Movie
+
+	// how many keys and controllers currently hold each input
+	held_buttons    [4]int
+	held_directions [4]int
+	held_turbo      [4]int
+
+	// turbo and macros advance once per frame
+	input_frames uint
+	turbo_rate   uint
+	turbo_start  uint
+	macro        Macro
+	macro_step   int
+	macro_frames int
 }
 
 type cpu_state struct {
@@ -19,8 +43,14 @@ func NewEmulator(logger *Logger) *Emulator {
 	cpu := NewCPU(logger)
 	ppu := NewPPU(logger)
 	InitMemory()
+	cgb = cgbMemory{}
+	sgb = sgbState{}
 	joy := NewJoypad()
-	e := &Emulator{cpu: cpu, ppu: ppu, joypad: joy, logger: logger}
+	serial := NewSerial()
+	watch := &watchState{}
+	e := &Emulator{cpu: cpu, ppu: ppu, joypad: joy, serial: serial, watch: watch, logger: logger, turbo_rate: DefaultTurboRate}
+	busJoypad = joy
+	busWatch = watch
 
 	return e
 }
@@ -33,6 +63,10 @@ func (emu *Emulator) GetCPU() *CPU {
 	return emu.cpu
 }
 
+func (emu *Emulator) GetSerial() *Serial {
+	return emu.serial
+}
+
 func (emu *Emulator) GetCPUState() cpu_state {
 	return cpu_state{cycles: emu.cpu.clk.cycles, registers: emu.cpu.reg, flags: emu.cpu.flg}
 }
@@ -41,11 +75,68 @@ func (emu *Emulator) GetCPUState() cpu_state {
 func (emu *Emulator) Reset() {
 	emu.cpu.Reset()
 	emu.ppu.Reset()
+	emu.serial.Reset()
+}
+
+func (emu *Emulator) LoadRom(rom *[]byte) {
+	for i, buffer := range *rom {
+		Write(uint16(i), buffer)
+	}
+	emu.rom = *rom
+	emu.rom_checksum = crc32.ChecksumIEEE(*rom)
+	emu.rom_loaded = true
+	emu.selectModel()
+	// the registers after boot differ between DMG and CGB
+	emu.cpu.Reset()
+}
+
+// Puts the whole machine back into the state right after loading the ROM.
+// Unlike Reset, this also clears memory and every internal counter.
+func (emu *Emulator) PowerOn() {
+	Memory = [65536]byte{}
+	InitMemory()
+	cgb = cgbMemory{}
+	sgb = sgbState{}
+	*emu.cpu.flg = Flags{}
+	*emu.cpu.clk = Clocks{MASTER_CLK: emu.cpu.clk.MASTER_CLK}
+	emu.cpu.pendingIME = false
+	emu.cpu.Reset()
+	emu.ppu.Reset()
+	*emu.joypad = *NewJoypad()
+	busJoypad = emu.joypad
+	// the watchpoints stay
+	busWatch = emu.watch
+	emu.watch.hit = nil
+	link := emu.serial.link
+	*emu.serial = *NewSerial()
+	emu.serial.link = link
+	emu.LoadRom(&emu.rom)
 }
 
 func (emu *Emulator) FetchDecodeExec() byte {
-	emu.cpu.Fetch()
+	if vramDMAStall() {
+		// the clock keeps running while a VRAM DMA copies
+		emu.cpu.clk.cycles++
+		emu.cpu.Handle_timer(1)
+		return 1
+	}
+
+	if dispatch := emu.cpu.Fetch(); dispatch != 0 {
+		// a step of its own, so the debugger sees the vector before the handler runs
+		emu.instructions++
+		cycles := dispatch / 4
+		emu.cpu.clk.cycles += uint(cycles)
+		emu.cpu.Handle_timer(cycles)
+		return cycles
+	}
+	if !emu.cpu.flg.HALT {
+		emu.instructions++
+	}
+	// only the accesses of the instruction count for watchpoints, not its fetch
+	emu.watch.pc = emu.cpu.reg.PC
+	emu.watch.cpu = true
 	cycles := emu.cpu.Decode()
+	emu.watch.cpu = false
 
 	emu.cpu.Handle_timer(cycles)
 	return cycles
@@ -57,66 +148,77 @@ func (emu *Emulator) Run() bool {
 	}
 
 	cycles := emu.FetchDecodeExec()
-	emu.joypad.updateControls()
-	return emu.ppu.Render(cycles)
+	emu.serial.update(cycles)
+	frame_ready := emu.ppu.Render(cycles)
+
+	if frame_ready && sgb.enabled {
+		sgbFrame()
+	}
+	if frame_ready {
+		emu.nextInputFrame()
+	}
+	if frame_ready && emu.movie != nil {
+		if emu.movie.finished() {
+			emu.StopMovie()
+		} else {
+			emu.movie.nextFrame(emu.joypad)
+		}
+	}
+	return frame_ready
 }
 
-func (emu *Emulator) PressButton(key string) {
-	switch key {
-	case "V":
-		emu.joypad.setButton(ButtonA)
-		return
-	case "C":
-		emu.joypad.setButton(ButtonB)
-		return
-	case "X":
-		emu.joypad.setButton(ButtonSelect)
-		return
-	case "Z":
-		emu.joypad.setButton(ButtonStart)
-		return
-	case "Up":
-		emu.joypad.setDirection(DirectionUp)
-		return
-	case "Down":
-		emu.joypad.setDirection(DirectionDown)
-		return
-	case "Left":
-		emu.joypad.setDirection(DirectionLeft)
-		return
-	case "Right":
-		emu.joypad.setDirection(DirectionRight)
-		return
+// Returns where key presses go: straight to the joypad, or to the movie,
+// which passes them on at the next frame.
+func (emu *Emulator) input() *Joypad {
+	if emu.movie != nil {
+		return &emu.movie.input
 	}
+	return emu.joypad
+}
 
+// Runs until the PPU finished a frame.
+// Returns false if no frame was completed within one frame's worth of cycles,
+// or a watchpoint was hit.
+func (emu *Emulator) RunFrame() bool {
+	max_render_time := (456 /* dots */ * 153 /* lines */ / 4 /* cpu cyc */)
+	if doubleSpeed() {
+		max_render_time *= 2
+	}
+	for range max_render_time {
+		if emu.Run() {
+			return true
+		}
+		if emu.watch.hit != nil {
+			return false
+		}
+	}
+	return false
 }
 
-func (emu *Emulator) ReleaseButton(key string) {
-	switch key {
-	case "V":
-		emu.joypad.resetButton(ButtonA)
-		return
-	case "C":
-		emu.joypad.resetButton(ButtonB)
-		return
-	case "X":
-		emu.joypad.resetButton(ButtonSelect)
-		return
-	case "Z":
-		emu.joypad.resetButton(ButtonStart)
-		return
-	case "Up":
-		emu.joypad.resetDirection(DirectionUp)
-		return
-	case "Down":
-		emu.joypad.resetDirection(DirectionDown)
-		return
-	case "Left":
-		emu.joypad.resetDirection(DirectionLeft)
-		return
-	case "Right":
-		emu.joypad.resetDirection(DirectionRight)
+// Inputs can be held by several keys and controllers at once,
+// they are only released once the last one lets go.
+func (emu *Emulator) PressButton(b Button) {
+	emu.held_buttons[b]++
+	emu.applyInput()
+}
+
+func (emu *Emulator) ReleaseButton(b Button) {
+	if emu.held_buttons[b] == 0 {
 		return
 	}
+	emu.held_buttons[b]--
+	emu.applyInput()
+}
+
+func (emu *Emulator) PressDirection(d Direction) {
+	emu.held_directions[d]++
+	emu.applyInput()
+}
 
+func (emu *Emulator) ReleaseDirection(d Direction) {
+	if emu.held_directions[d] == 0 {
+		return
+	}
+	emu.held_directions[d]--
+	emu.applyInput()
 }
@@ -0,0 +1,36 @@
+package maybego
+
+import (
+	"os"
+	"testing"
+)
+
+// Creates a fresh emulator with the ROM at path loaded.
+func loadTestRom(t *testing.T, path string) *Emulator {
+	t.Helper()
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	emu.LoadRom(&rom)
+	return emu
+}
+
+// Builds a ROM without an MBC with the program at 0x100, where the CPU starts.
+func programRom(program ...byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], program)
+	return rom
+}
+
+// Loads the ROM into a new emulator of the given model, with cleared memory.
+func loadProgram(rom []byte, model Model) *Emulator {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	emu.SetModel(model)
+	emu.LoadRom(&rom)
+	return emu
+}
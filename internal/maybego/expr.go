@@ -0,0 +1,295 @@
+package maybego
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A condition of a breakpoint or a value logged by a tracepoint,
+// e.g. "A == 0x3F && [HL] > 10 && LY == 144".
+// Values are integers, comparisons and logic operators give 1 or 0.
+// Names are registers (A, F, AF, BC, HL, SP, PC, ...), flags (ZF, NF, HF, CF, IME)
+// and I/O registers (LY, LCDC, ...), which read the byte at their address.
+// [x] reads the byte at address x. Numbers are decimal, or hex with 0x or $.
+type Expr struct {
+	text string
+	eval exprFunc
+}
+
+type exprFunc func(emu *Emulator) int
+
+// Binary operators from the lowest to the highest precedence, like in C.
+var exprLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func exprBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var exprBinary = map[string]func(a, b int) int{
+	"||": func(a, b int) int { return exprBool(a != 0 || b != 0) },
+	"&&": func(a, b int) int { return exprBool(a != 0 && b != 0) },
+	"|":  func(a, b int) int { return a | b },
+	"^":  func(a, b int) int { return a ^ b },
+	"&":  func(a, b int) int { return a & b },
+	"==": func(a, b int) int { return exprBool(a == b) },
+	"!=": func(a, b int) int { return exprBool(a != b) },
+	"<":  func(a, b int) int { return exprBool(a < b) },
+	"<=": func(a, b int) int { return exprBool(a <= b) },
+	">":  func(a, b int) int { return exprBool(a > b) },
+	">=": func(a, b int) int { return exprBool(a >= b) },
+	"<<": func(a, b int) int { return a << (b & 0x3F) },
+	">>": func(a, b int) int { return a >> (b & 0x3F) },
+	"+":  func(a, b int) int { return a + b },
+	"-":  func(a, b int) int { return a - b },
+	"*":  func(a, b int) int { return a * b },
+	// dividing by 0 gives 0 instead of stopping the emulator
+	"/": func(a, b int) int {
+		if b == 0 {
+			return 0
+		}
+		return a / b
+	},
+	"%": func(a, b int) int {
+		if b == 0 {
+			return 0
+		}
+		return a % b
+	},
+}
+
+// Longer operators first, so "<=" is not read as "<".
+var exprOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"|", "^", "&", "<", ">", "+", "-", "*", "/", "%", "!", "~", "(", ")", "[", "]"}
+
+func flagBit(flag bool, bit uint) int {
+	return exprBool(flag) << bit
+}
+
+func exprFlags(emu *Emulator) int {
+	flg := emu.cpu.flg
+	return flagBit(flg.Z, 7) | flagBit(flg.N, 6) | flagBit(flg.H, 5) | flagBit(flg.C, 4)
+}
+
+var exprRegisters = map[string]exprFunc{
+	"A":   func(emu *Emulator) int { return int(emu.cpu.reg.A) },
+	"B":   func(emu *Emulator) int { return int(emu.cpu.reg.B) },
+	"C":   func(emu *Emulator) int { return int(emu.cpu.reg.C) },
+	"D":   func(emu *Emulator) int { return int(emu.cpu.reg.D) },
+	"E":   func(emu *Emulator) int { return int(emu.cpu.reg.E) },
+	"H":   func(emu *Emulator) int { return int(emu.cpu.reg.H) },
+	"L":   func(emu *Emulator) int { return int(emu.cpu.reg.L) },
+	"F":   exprFlags,
+	"AF":  func(emu *Emulator) int { return int(emu.cpu.reg.A)<<8 | exprFlags(emu) },
+	"BC":  func(emu *Emulator) int { return int(emu.cpu.reg.B)<<8 | int(emu.cpu.reg.C) },
+	"DE":  func(emu *Emulator) int { return int(emu.cpu.reg.D)<<8 | int(emu.cpu.reg.E) },
+	"HL":  func(emu *Emulator) int { return int(emu.cpu.reg.H)<<8 | int(emu.cpu.reg.L) },
+	"SP":  func(emu *Emulator) int { return int(emu.cpu.reg.SP) },
+	"PC":  func(emu *Emulator) int { return int(emu.cpu.reg.PC) },
+	"ZF":  func(emu *Emulator) int { return exprBool(emu.cpu.flg.Z) },
+	"NF":  func(emu *Emulator) int { return exprBool(emu.cpu.flg.N) },
+	"HF":  func(emu *Emulator) int { return exprBool(emu.cpu.flg.H) },
+	"CF":  func(emu *Emulator) int { return exprBool(emu.cpu.flg.C) },
+	"IME": func(emu *Emulator) int { return exprBool(emu.cpu.flg.IME) },
+}
+
+var ioNames = map[string]uint16{
+	"JOYP": JOYP, "P1": JOYP, "SB": SB, "SC": SC,
+	"DIV": DIV, "TIMA": TIMA, "TMA": TMA, "TAC": TAC, "IF": IF, "IE": IE,
+	"LCDC": LCDC, "STAT": STAT, "SCY": 0xFF42, "SCX": 0xFF43, "LY": LY, "LYC": LYC,
+	"DMA": 0xFF46, "BGP": BGP, "OBP0": 0xFF48, "OBP1": 0xFF49, "WY": 0xFF4A, "WX": 0xFF4B,
+	"KEY1": KEY1, "VBK": VBK, "HDMA1": HDMA1, "HDMA2": HDMA2, "HDMA3": HDMA3, "HDMA4": HDMA4, "HDMA5": HDMA5,
+	"BCPS": BCPS, "BCPD": BCPD, "OCPS": OCPS, "OCPD": OCPD, "SVBK": SVBK,
+}
+
+type exprToken struct {
+	text string
+	pos  int
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	end    int // position of the end of the text, for errors
+}
+
+func ParseExpr(text string) (*Expr, error) {
+	tokens, err := tokenizeExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &exprParser{tokens: tokens, end: len(text)}
+	eval, err := parser.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, parser.unexpected()
+	}
+	return &Expr{text: text, eval: eval}, nil
+}
+
+func (expr *Expr) Eval(emu *Emulator) int {
+	return expr.eval(emu)
+}
+
+func (expr *Expr) String() string {
+	return expr.text
+}
+
+func isExprNameByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func tokenizeExpr(text string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == ' ' || text[i] == '\t':
+			i++
+		case isExprNameByte(text[i]):
+			start := i
+			for i < len(text) && isExprNameByte(text[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{text: text[start:i], pos: start})
+		default:
+			index := slices.IndexFunc(exprOperators, func(op string) bool {
+				return strings.HasPrefix(text[i:], op)
+			})
+			if index < 0 {
+				return nil, fmt.Errorf("unexpected %q at %d", text[i], i)
+			}
+			tokens = append(tokens, exprToken{text: exprOperators[index], pos: i})
+			i += len(exprOperators[index])
+		}
+	}
+	return tokens, nil
+}
+
+func (parser *exprParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos].text
+	}
+	return ""
+}
+
+func (parser *exprParser) unexpected() error {
+	if parser.pos >= len(parser.tokens) {
+		return fmt.Errorf("unexpected end at %d", parser.end)
+	}
+	token := parser.tokens[parser.pos]
+	return fmt.Errorf("unexpected %q at %d", token.text, token.pos)
+}
+
+func (parser *exprParser) expect(text string) error {
+	if parser.peek() != text {
+		return parser.unexpected()
+	}
+	parser.pos++
+	return nil
+}
+
+func (parser *exprParser) binary(level int) (exprFunc, error) {
+	if level == len(exprLevels) {
+		return parser.unary()
+	}
+	left, err := parser.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for slices.Contains(exprLevels[level], parser.peek()) {
+		op := exprBinary[parser.peek()]
+		parser.pos++
+		right, err := parser.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		a := left
+		left = func(emu *Emulator) int { return op(a(emu), right(emu)) }
+	}
+	return left, nil
+}
+
+func (parser *exprParser) unary() (exprFunc, error) {
+	op := parser.peek()
+	if op != "-" && op != "!" && op != "~" {
+		return parser.primary()
+	}
+	parser.pos++
+	operand, err := parser.unary()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "-":
+		return func(emu *Emulator) int { return -operand(emu) }, nil
+	case "!":
+		return func(emu *Emulator) int { return exprBool(operand(emu) == 0) }, nil
+	}
+	return func(emu *Emulator) int { return ^operand(emu) }, nil
+}
+
+func (parser *exprParser) primary() (exprFunc, error) {
+	token := parser.peek()
+	switch {
+	case token == "(":
+		parser.pos++
+		inner, err := parser.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return inner, parser.expect(")")
+	case token == "[":
+		parser.pos++
+		address, err := parser.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return func(emu *Emulator) int { return int(DebugRead(uint16(address(emu)))) }, parser.expect("]")
+	case token == "" || !isExprNameByte(token[0]):
+		return nil, parser.unexpected()
+	}
+
+	parser.pos++
+	if token[0] == '$' || (token[0] >= '0' && token[0] <= '9') {
+		value, err := parseExprNumber(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", token, parser.tokens[parser.pos-1].pos)
+		}
+		return func(*Emulator) int { return value }, nil
+	}
+
+	name := strings.ToUpper(token)
+	if register, ok := exprRegisters[name]; ok {
+		return register, nil
+	}
+	if address, ok := ioNames[name]; ok {
+		return func(*Emulator) int { return int(DebugRead(address)) }, nil
+	}
+	return nil, fmt.Errorf("unknown name %q at %d", token, parser.tokens[parser.pos-1].pos)
+}
+
+func parseExprNumber(text string) (int, error) {
+	if strings.HasPrefix(text, "$") || strings.HasPrefix(strings.ToLower(text), "0x") {
+		value, err := parseHex(strings.Replace(text, "0X", "0x", 1), 32)
+		return int(value), err
+	}
+	value, err := strconv.ParseUint(text, 10, 32)
+	return int(value), err
+}
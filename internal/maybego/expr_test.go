@@ -0,0 +1,68 @@
+package maybego
+
+import (
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	*emu.cpu.reg = Registers{A: 0x3F, B: 0x12, C: 0x34, H: 0xC0, L: 0x10, SP: 0xFFFE, PC: 0x0150}
+	*emu.cpu.flg = Flags{Z: true, C: true}
+	DebugWrite(0xC010, 11)
+	DebugWrite(LY, 144)
+
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"A == 0x3F && [HL] > 10 && LY == 144", 1},
+		{"a == $3f", 1},
+		{"BC", 0x1234},
+		{"HL + 1", 0xC011},
+		{"AF", 0x3F90},
+		{"ZF && !NF && CF", 1},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 2 - 3", 5},
+		{"-1 + 2", 1},
+		{"~0 & 0xFF", 0xFF},
+		{"1 << 4 | 1", 0x11},
+		{"[0xC000 + 0x10] % 4", 3},
+		{"PC >= 0x100 && SP != 0", 1},
+		{"A < 3 || B == 18", 1},
+		{"5 / 0", 0},
+		{"3 ^ 1", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			expr, err := ParseExpr(test.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := expr.Eval(emu); actual != test.expected {
+				t.Errorf("Current value: %x; expected: %x", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"A ==",
+		"(A",
+		"[HL",
+		"A B",
+		"XYZ == 1",
+		"0xZZ",
+		"A # 1",
+	}
+
+	for _, text := range tests {
+		if _, err := ParseExpr(text); err == nil {
+			t.Errorf("%q parsed, expected an error", text)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package maybego
+
+import "errors"
+
+var ErrNoGamepadSupport = errors.New("built without gamepad support, rebuild with -tags sdl")
+
+// What a single controller currently holds, so everything can be released
+// again when it gets unplugged.
+type gamepadState struct {
+	held  map[string]bool // controller button names
+	stick [4]bool         // indexed by Direction
+	x     int16
+	y     int16
+}
+
+func newGamepadState() *gamepadState {
+	return &gamepadState{held: map[string]bool{}}
+}
+
+func (pad *gamepadState) pressButton(emu *Emulator, keys keyMap, name string) {
+	if pad.held[name] {
+		return
+	}
+	if keys.press(emu, name) {
+		pad.held[name] = true
+	}
+}
+
+func (pad *gamepadState) releaseButton(emu *Emulator, keys keyMap, name string) {
+	if !pad.held[name] {
+		return
+	}
+	keys.release(emu, name)
+	delete(pad.held, name)
+}
+
+// Turns the left stick position into D-pad input.
+func (pad *gamepadState) moveStick(emu *Emulator, deadzone int, x int16, y int16) {
+	pad.x = x
+	pad.y = y
+	if deadzone == 0 {
+		return
+	}
+
+	var wanted [4]bool
+	wanted[DirectionLeft] = int(x) < -deadzone
+	wanted[DirectionRight] = int(x) > deadzone
+	wanted[DirectionUp] = int(y) < -deadzone
+	wanted[DirectionDown] = int(y) > deadzone
+
+	for d := range wanted {
+		if wanted[d] == pad.stick[d] {
+			continue
+		}
+		if wanted[d] {
+			emu.PressDirection(Direction(d))
+		} else {
+			emu.ReleaseDirection(Direction(d))
+		}
+		pad.stick[d] = wanted[d]
+	}
+}
+
+func (pad *gamepadState) releaseAll(emu *Emulator, keys keyMap) {
+	for name := range pad.held {
+		pad.releaseButton(emu, keys, name)
+	}
+	pad.moveStick(emu, 1, 0, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !sdl
+
+package maybego
+
+// Without SDL there are no controllers, only the keyboard.
+type Gamepads struct{}
+
+func OpenGamepads(emu *Emulator, config *GamepadConfig, macros map[string]Macro) (*Gamepads, error) {
+	return nil, ErrNoGamepadSupport
+}
+
+func (pads *Gamepads) Poll() {}
+
+func (pads *Gamepads) Close() {}
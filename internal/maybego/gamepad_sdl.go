@@ -0,0 +1,116 @@
+//go:build sdl
+
+package maybego
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// SDL GameControllers as an input backend. Controllers can be plugged in and
+// out at any time, their input is merged with the keyboard by the Emulator.
+type Gamepads struct {
+	emu         *Emulator
+	keys        keyMap
+	deadzone    int
+	controllers map[sdl.JoystickID]*sdl.GameController
+	states      map[sdl.JoystickID]*gamepadState
+}
+
+// Controllers that are already connected show up with the first Poll.
+func OpenGamepads(emu *Emulator, config *GamepadConfig, macros map[string]Macro) (*Gamepads, error) {
+	if err := sdl.InitSubSystem(sdl.INIT_GAMECONTROLLER); err != nil {
+		return nil, err
+	}
+
+	pads := &Gamepads{
+		emu:         emu,
+		keys:        config.Buttons.keyMap(),
+		deadzone:    config.StickDeadzone,
+		controllers: map[sdl.JoystickID]*sdl.GameController{},
+		states:      map[sdl.JoystickID]*gamepadState{},
+	}
+	pads.keys.macros = macros
+	return pads, nil
+}
+
+// Handles all pending controller events, should be called once per frame.
+func (pads *Gamepads) Poll() {
+	if pads == nil {
+		return
+	}
+
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+		case *sdl.ControllerDeviceEvent:
+			if e.Type == sdl.CONTROLLERDEVICEADDED {
+				// Which is the device index here, but the instance id on removal
+				pads.open(int(e.Which))
+			} else if e.Type == sdl.CONTROLLERDEVICEREMOVED {
+				pads.close(e.Which)
+			}
+		case *sdl.ControllerButtonEvent:
+			state, ok := pads.states[e.Which]
+			if !ok {
+				continue
+			}
+			name := sdl.GameControllerGetStringForButton(sdl.GameControllerButton(e.Button))
+			if e.State == sdl.PRESSED {
+				state.pressButton(pads.emu, pads.keys, name)
+			} else {
+				state.releaseButton(pads.emu, pads.keys, name)
+			}
+		case *sdl.ControllerAxisEvent:
+			state, ok := pads.states[e.Which]
+			if !ok {
+				continue
+			}
+			switch sdl.GameControllerAxis(e.Axis) {
+			case sdl.CONTROLLER_AXIS_LEFTX:
+				state.moveStick(pads.emu, pads.deadzone, e.Value, state.y)
+			case sdl.CONTROLLER_AXIS_LEFTY:
+				state.moveStick(pads.emu, pads.deadzone, state.x, e.Value)
+			}
+		}
+	}
+}
+
+func (pads *Gamepads) open(index int) {
+	if !sdl.IsGameController(index) {
+		return
+	}
+	controller := sdl.GameControllerOpen(index)
+	if controller == nil {
+		return
+	}
+
+	id := controller.Joystick().InstanceID()
+	if _, ok := pads.controllers[id]; ok {
+		controller.Close()
+		return
+	}
+	pads.controllers[id] = controller
+	pads.states[id] = newGamepadState()
+}
+
+func (pads *Gamepads) close(id sdl.JoystickID) {
+	controller, ok := pads.controllers[id]
+	if !ok {
+		return
+	}
+
+	pads.states[id].releaseAll(pads.emu, pads.keys)
+	controller.Close()
+	delete(pads.controllers, id)
+	delete(pads.states, id)
+}
+
+func (pads *Gamepads) Close() {
+	if pads == nil {
+		return
+	}
+
+	for id := range pads.controllers {
+		pads.close(id)
+	}
+	sdl.QuitSubSystem(sdl.INIT_GAMECONTROLLER)
+}
@@ -0,0 +1,53 @@
+package maybego
+
+import (
+	"testing"
+)
+
+func TestGamepadStick(t *testing.T) {
+	var tests = []struct {
+		x                  int16
+		y                  int16
+		expectedDirections byte
+	}{
+		{0, 0, 0xF},
+		{-7999, 7999, 0xF},
+		{-8001, 0, 0xD},
+		{8001, 0, 0xE},
+		{8001, -8001, 0xA},
+		{0, 32767, 0x7},
+		{0, 0, 0xF},
+	}
+
+	emu := NewEmulator(logger)
+	pad := newGamepadState()
+	for _, test := range tests {
+		pad.moveStick(emu, 8000, test.x, test.y)
+		if emu.joypad.directions != test.expectedDirections {
+			t.Errorf("Current directions: %x; expected: %x", emu.joypad.directions, test.expectedDirections)
+			t.Errorf("Test: {x: %d, y: %d}", test.x, test.y)
+		}
+	}
+}
+
+func TestGamepadMergesWithKeyboard(t *testing.T) {
+	emu := NewEmulator(logger)
+	config := DefaultConfig()
+	keyboard := config.Keys.keyMap()
+	buttons := config.Gamepad.Buttons.keyMap()
+	pad := newGamepadState()
+
+	keyboard.press(emu, "V")
+	pad.pressButton(emu, buttons, "a")
+	keyboard.release(emu, "V")
+	if emu.joypad.buttons != 0xE {
+		t.Errorf("Current buttons: %x; expected A to be held by the controller", emu.joypad.buttons)
+	}
+
+	pad.pressButton(emu, buttons, "start")
+	pad.moveStick(emu, 8000, 0, -10000)
+	pad.releaseAll(emu, buttons) // unplugged
+	if emu.joypad.buttons != 0xF || emu.joypad.directions != 0xF {
+		t.Errorf("Current buttons: %x directions: %x; expected everything to be released", emu.joypad.buttons, emu.joypad.directions)
+	}
+}
@@ -0,0 +1,464 @@
+package maybego
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// There is no SM83 target in GDB, the stub describes its registers itself:
+// the register pairs, each 16 bits and little-endian in 'g' packets.
+const gdbTargetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.maybego.sm83">
+    <reg name="af" bitsize="16" type="uint16"/>
+    <reg name="bc" bitsize="16" type="uint16"/>
+    <reg name="de" bitsize="16" type="uint16"/>
+    <reg name="hl" bitsize="16" type="uint16"/>
+    <reg name="sp" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>`
+
+// How many instructions a continue runs between checks for an interrupt from GDB.
+const gdbPollInstructions = 10000
+
+// A step gives up after this many cycles without an instruction, e.g. while halted.
+const gdbStepCycles = 456 * 154
+
+// Sent by GDB to interrupt a continue.
+const gdbInterrupt byte = 0x03
+
+// A packet from GDB, or an interrupt.
+type gdbEvent struct {
+	packet    string
+	interrupt bool
+}
+
+// Serves one GDB session over the remote serial protocol: registers,
+// memory, breakpoints, watchpoints, continue and single-step.
+type GDBStub struct {
+	emu    *Emulator
+	dbg    *Debugger
+	conn   io.ReadWriter
+	events chan gdbEvent
+	// packets that arrived during a continue, answered after its stop reply
+	queued []gdbEvent
+	// set by Z0/Z1 packets, removed by z0/z1
+	breakpoints map[uint16]*Breakpoint
+}
+
+var errGDBDetached = errors.New("gdb detached")
+
+// Waits for GDB to connect to addr, e.g. ":2345", and serves it until it detaches.
+func ServeGDB(emu *Emulator, dbg *Debugger, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return NewGDBStub(emu, dbg, conn).Serve()
+}
+
+func NewGDBStub(emu *Emulator, dbg *Debugger, conn io.ReadWriter) *GDBStub {
+	return &GDBStub{emu: emu, dbg: dbg, conn: conn, events: make(chan gdbEvent, 16), breakpoints: map[uint16]*Breakpoint{}}
+}
+
+// Handles packets until GDB detaches, kills the target or the connection closes.
+func (stub *GDBStub) Serve() error {
+	go stub.read()
+	for {
+		event, ok := stub.next()
+		if !ok {
+			return nil
+		}
+		if event.interrupt {
+			continue
+		}
+		reply, err := stub.handle(event.packet)
+		if err == errGDBDetached {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stub.send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the queued packets first, then the ones GDB sends.
+func (stub *GDBStub) next() (gdbEvent, bool) {
+	if len(stub.queued) > 0 {
+		event := stub.queued[0]
+		stub.queued = stub.queued[1:]
+		return event, true
+	}
+	event, ok := <-stub.events
+	return event, ok
+}
+
+// Splits what GDB sends into packets, acknowledging them unless GDB turned that off.
+// Runs in its own goroutine, so an interrupt arrives while the emulator runs.
+func (stub *GDBStub) read() {
+	defer close(stub.events)
+	reader := bufio.NewReader(stub.conn)
+	// only known here, query answers QStartNoAckMode on the emulator goroutine
+	no_ack := false
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch c {
+		case gdbInterrupt:
+			stub.events <- gdbEvent{interrupt: true}
+		case '$':
+			data, err := reader.ReadString('#')
+			if err != nil {
+				return
+			}
+			var checksum [2]byte
+			if _, err := io.ReadFull(reader, checksum[:]); err != nil {
+				return
+			}
+			packet := strings.TrimSuffix(data, "#")
+			if !no_ack {
+				if fmt.Sprintf("%02x", gdbChecksum(packet)) != strings.ToLower(string(checksum[:])) {
+					stub.conn.Write([]byte("-"))
+					continue
+				}
+				stub.conn.Write([]byte("+"))
+			}
+			// this packet is still acknowledged, the ones after it are not
+			no_ack = no_ack || packet == "QStartNoAckMode"
+			stub.events <- gdbEvent{packet: packet}
+		}
+		// acknowledgements of our packets are not checked, TCP does not lose them
+	}
+}
+
+func gdbChecksum(data string) byte {
+	var sum byte
+	for i := range len(data) {
+		sum += data[i]
+	}
+	return sum
+}
+
+func (stub *GDBStub) send(data string) error {
+	_, err := fmt.Fprintf(stub.conn, "$%s#%02x", data, gdbChecksum(data))
+	return err
+}
+
+func (stub *GDBStub) handle(packet string) (string, error) {
+	if packet == "" {
+		return "", nil
+	}
+	args := packet[1:]
+	switch packet[0] {
+	case '?':
+		return "S05", nil
+	case 'g':
+		return stub.readRegisters(), nil
+	case 'G':
+		return stub.writeRegisters(args), nil
+	case 'p':
+		return stub.readRegister(args), nil
+	case 'P':
+		return stub.writeRegister(args), nil
+	case 'm':
+		return stub.readMemory(args), nil
+	case 'M':
+		return stub.writeMemory(args), nil
+	case 'Z', 'z':
+		return stub.setPoint(packet[0] == 'Z', args), nil
+	case 'c':
+		return stub.cont(args), nil
+	case 's':
+		return stub.step(args), nil
+	case 'H':
+		return "OK", nil
+	case 'D':
+		stub.send("OK")
+		return "", errGDBDetached
+	case 'k':
+		return "", errGDBDetached
+	case 'q', 'Q':
+		return stub.query(packet), nil
+	}
+	return "", nil
+}
+
+func (stub *GDBStub) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+"
+	case packet == "QStartNoAckMode":
+		// read turned the acknowledgements off
+		return "OK"
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		var offset, length int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(packet, "qXfer:features:read:target.xml:"), "%x,%x", &offset, &length); err != nil {
+			return "E01"
+		}
+		if offset >= len(gdbTargetXML) {
+			return "l"
+		}
+		end := min(offset+length, len(gdbTargetXML))
+		if end == len(gdbTargetXML) {
+			return "l" + gdbTargetXML[offset:end]
+		}
+		return "m" + gdbTargetXML[offset:end]
+	}
+	return ""
+}
+
+// The register pairs in the order of gdbTargetXML.
+func (stub *GDBStub) registers() [6]uint16 {
+	reg := stub.emu.cpu.reg
+	return [6]uint16{
+		uint16(reg.A)<<8 | uint16(exprFlags(stub.emu)),
+		uint16(reg.B)<<8 | uint16(reg.C),
+		uint16(reg.D)<<8 | uint16(reg.E),
+		uint16(reg.H)<<8 | uint16(reg.L),
+		reg.SP,
+		reg.PC,
+	}
+}
+
+func (stub *GDBStub) setRegister(index int, val uint16) bool {
+	reg, flg := stub.emu.cpu.reg, stub.emu.cpu.flg
+	switch index {
+	case 0:
+		reg.A = byte(val >> 8)
+		flg.Z, flg.N, flg.H, flg.C = val&0x80 != 0, val&0x40 != 0, val&0x20 != 0, val&0x10 != 0
+	case 1:
+		reg.B, reg.C = byte(val>>8), byte(val)
+	case 2:
+		reg.D, reg.E = byte(val>>8), byte(val)
+	case 3:
+		reg.H, reg.L = byte(val>>8), byte(val)
+	case 4:
+		reg.SP = val
+	case 5:
+		reg.PC = val
+	default:
+		return false
+	}
+	return true
+}
+
+func gdbHex16(val uint16) string {
+	return hex.EncodeToString([]byte{byte(val), byte(val >> 8)})
+}
+
+func parseGDBHex16(text string) (uint16, bool) {
+	data, err := hex.DecodeString(text)
+	if err != nil || len(data) != 2 {
+		return 0, false
+	}
+	return uint16(data[0]) | uint16(data[1])<<8, true
+}
+
+func (stub *GDBStub) readRegisters() string {
+	var reply strings.Builder
+	for _, val := range stub.registers() {
+		reply.WriteString(gdbHex16(val))
+	}
+	return reply.String()
+}
+
+func (stub *GDBStub) writeRegisters(args string) string {
+	if len(args) != 6*4 {
+		return "E01"
+	}
+	for i := range 6 {
+		val, ok := parseGDBHex16(args[i*4 : i*4+4])
+		if !ok {
+			return "E01"
+		}
+		stub.setRegister(i, val)
+	}
+	return "OK"
+}
+
+func (stub *GDBStub) readRegister(args string) string {
+	index, err := strconv.ParseUint(args, 16, 8)
+	if err != nil || index >= 6 {
+		return "E01"
+	}
+	return gdbHex16(stub.registers()[index])
+}
+
+func (stub *GDBStub) writeRegister(args string) string {
+	number, value, _ := strings.Cut(args, "=")
+	index, err := strconv.ParseUint(number, 16, 8)
+	val, ok := parseGDBHex16(value)
+	if err != nil || !ok || !stub.setRegister(int(index), val) {
+		return "E01"
+	}
+	return "OK"
+}
+
+// Parses "addr,length" and checks it stays within the address space.
+func parseGDBRange(args string) (uint16, int, bool) {
+	address, length, _ := strings.Cut(args, ",")
+	adr, err := strconv.ParseUint(address, 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseUint(length, 16, 32)
+	if err != nil || adr+n > 0x10000 {
+		return 0, 0, false
+	}
+	return uint16(adr), int(n), true
+}
+
+func (stub *GDBStub) readMemory(args string) string {
+	adr, length, ok := parseGDBRange(args)
+	if !ok {
+		return "E01"
+	}
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = DebugRead(adr + uint16(i))
+	}
+	return hex.EncodeToString(data)
+}
+
+func (stub *GDBStub) writeMemory(args string) string {
+	area, values, _ := strings.Cut(args, ":")
+	adr, length, ok := parseGDBRange(area)
+	data, err := hex.DecodeString(values)
+	if !ok || err != nil || len(data) != length {
+		return "E01"
+	}
+	for i, val := range data {
+		DebugWrite(adr+uint16(i), val)
+	}
+	return "OK"
+}
+
+// Z/z type,addr,kind: 0 and 1 are breakpoints, 2 write, 3 read and 4 access watchpoints.
+func (stub *GDBStub) setPoint(insert bool, args string) string {
+	fields := strings.Split(args, ",")
+	if len(fields) < 3 {
+		return "E01"
+	}
+	adr, length, ok := parseGDBRange(fields[1] + "," + fields[2])
+	if !ok {
+		return "E01"
+	}
+
+	switch fields[0] {
+	case "0", "1":
+		if bp, exists := stub.breakpoints[adr]; exists && !insert {
+			stub.dbg.RemoveBreakpoint(bp)
+			delete(stub.breakpoints, adr)
+		} else if !exists && insert {
+			stub.breakpoints[adr], _ = stub.dbg.AddBreakpoint(adr, "")
+		}
+		return "OK"
+	case "2", "3", "4":
+		kind := map[string]WatchKind{"2": WatchWrite, "3": WatchRead, "4": WatchRead | WatchWrite}[fields[0]]
+		point := Watchpoint{Start: adr, End: adr + uint16(max(length, 1)-1), Kind: kind}
+		if insert {
+			stub.emu.AddWatchpoint(point)
+			return "OK"
+		}
+		for i, other := range stub.emu.Watchpoints() {
+			if other == point {
+				stub.emu.RemoveWatchpoint(i)
+				break
+			}
+		}
+		return "OK"
+	}
+	return ""
+}
+
+// c and s may give the address to continue at.
+func (stub *GDBStub) resumeAt(args string) {
+	if adr, err := strconv.ParseUint(args, 16, 16); err == nil {
+		stub.emu.cpu.reg.PC = uint16(adr)
+	}
+}
+
+// Runs one instruction, returns a stop reply if it hit a watchpoint.
+func (stub *GDBStub) runInstruction() string {
+	before := stub.emu.instructions
+	for cycles := 0; stub.emu.instructions == before && cycles < gdbStepCycles; cycles++ {
+		stub.emu.Run()
+		if hit := stub.emu.WatchHit(); hit != nil {
+			return stopReplyWatch(hit)
+		}
+	}
+	return ""
+}
+
+func stopReplyWatch(hit *WatchHit) string {
+	kind := "watch"
+	switch {
+	case hit.Watchpoint.Kind&WatchRead != 0 && hit.Watchpoint.Kind&WatchWrite != 0:
+		kind = "awatch"
+	case hit.Watchpoint.Kind&WatchRead != 0:
+		kind = "rwatch"
+	}
+	return fmt.Sprintf("T05%s:%04x;", kind, hit.Address)
+}
+
+func (stub *GDBStub) step(args string) string {
+	stub.resumeAt(args)
+	if reply := stub.runInstruction(); reply != "" {
+		return reply
+	}
+	return "S05"
+}
+
+// Runs until a breakpoint or watchpoint is hit or GDB interrupts.
+func (stub *GDBStub) cont(args string) string {
+	stub.resumeAt(args)
+	for n := 0; ; n++ {
+		if reply := stub.runInstruction(); reply != "" {
+			return reply
+		}
+		if stub.dbg.Check() {
+			return "T05swbreak:;"
+		}
+		if n%gdbPollInstructions == 0 {
+			select {
+			case event, ok := <-stub.events:
+				if !ok {
+					return "X09"
+				}
+				if event.interrupt {
+					return "S02"
+				}
+				stub.queued = append(stub.queued, event)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package maybego
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A GDB client on a local TCP connection to a stub serving emu.
+type gdbClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	done   chan error
+	err    error
+	closed bool
+}
+
+func connectGDB(t *testing.T, emu *Emulator) *gdbClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		done <- NewGDBStub(emu, NewDebugger(emu), conn).Serve()
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &gdbClient{t: t, conn: conn, reader: bufio.NewReader(conn), done: done}
+	// the stub has to stop before the next test loads a ROM
+	t.Cleanup(func() { client.close() })
+	return client
+}
+
+// Closes the connection and waits for the stub to return.
+func (client *gdbClient) close() error {
+	if !client.closed {
+		client.closed = true
+		client.conn.Close()
+		client.err = <-client.done
+	}
+	return client.err
+}
+
+// Sends a packet and returns the reply.
+func (client *gdbClient) command(packet string) string {
+	client.t.Helper()
+	fmt.Fprintf(client.conn, "$%s#%02x", packet, gdbChecksum(packet))
+	if ack, err := client.reader.ReadByte(); err != nil || ack != '+' {
+		client.t.Fatalf("Current ack of %s: %q, %v; expected: '+'", packet, ack, err)
+	}
+	return client.reply()
+}
+
+func (client *gdbClient) reply() string {
+	client.t.Helper()
+	if _, err := client.reader.ReadString('$'); err != nil {
+		client.t.Fatal(err)
+	}
+	data, err := client.reader.ReadString('#')
+	if err != nil {
+		client.t.Fatal(err)
+	}
+	var checksum [2]byte
+	client.reader.Read(checksum[:])
+	client.conn.Write([]byte("+"))
+	data = strings.TrimSuffix(data, "#")
+	if fmt.Sprintf("%02x", gdbChecksum(data)) != string(checksum[:]) {
+		client.t.Errorf("Current checksum of %q: %s", data, checksum)
+	}
+	return data
+}
+
+func TestGDBCommands(t *testing.T) {
+	client := connectGDB(t, loadLoopProgram())
+
+	tests := []struct {
+		packet   string
+		expected string
+	}{
+		{"qSupported:swbreak+", "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+"},
+		{"?", "S05"},
+		{"g", "b0011303d8004d01feff0001"},
+		{"p5", "0001"},
+		{"P3=3412", "OK"},
+		{"p3", "3412"},
+		{"p6", "E01"},
+		{"m100,4", "0520fd76"},
+		{"MC000,2:beef", "OK"},
+		{"mC000,2", "beef"},
+		{"mFFFF,2", "E01"},
+		{"qXfer:features:read:target.xml:0,10", "m<?xml version=\"1"},
+		{"vMustReplyEmpty", ""},
+	}
+	for _, test := range tests {
+		if reply := client.command(test.packet); reply != test.expected {
+			t.Errorf("Current reply to %s: %q; expected: %q", test.packet, reply, test.expected)
+		}
+	}
+}
+
+func TestGDBBreakpointAndStep(t *testing.T) {
+	emu := loadLoopProgram()
+	client := connectGDB(t, emu)
+
+	steps := []struct {
+		packet   string
+		expected string
+		pc       uint16
+	}{
+		{"s", "S05", 0x101},
+		{"s", "S05", 0x100},
+		{"Z0,103,1", "OK", 0x100},
+		// B counts down from 3 to 0, then the loop ends at the HALT
+		{"c", "T05swbreak:;", 0x103},
+		{"z0,103,1", "OK", 0x103},
+		{"G" + strings.Repeat("0", 20) + "0001", "OK", 0x100},
+		{"Z0,101,1", "OK", 0x100},
+		// B wrapped to FF, the JR is reached again
+		{"c", "T05swbreak:;", 0x101},
+	}
+	for _, step := range steps {
+		if reply := client.command(step.packet); reply != step.expected {
+			t.Errorf("Current reply to %s: %q; expected: %q", step.packet, reply, step.expected)
+		}
+		if pc := client.command("p5"); pc != gdbHex16(step.pc) {
+			t.Errorf("Current PC after %s: %s; expected: %s", step.packet, pc, gdbHex16(step.pc))
+		}
+	}
+
+	client.command("D")
+	if err := client.close(); err != nil {
+		t.Errorf("Current error after detaching: %v; expected: nil", err)
+	}
+}
+
+func TestGDBWatchpoints(t *testing.T) {
+	tests := []struct {
+		packet   string
+		expected string
+		pc       uint16
+	}{
+		{"Z2,c001,1", "T05watch:c001;", 0x106},
+		{"Z3,c000,1", "T05rwatch:c000;", 0x103},
+		{"Z4,c000,2", "T05awatch:c000;", 0x103},
+	}
+	for _, test := range tests {
+		emu := loadWatchProgram()
+		client := connectGDB(t, emu)
+		client.command(test.packet)
+		if reply := client.command("c"); reply != test.expected {
+			t.Errorf("Current reply to %s: %q; expected: %q", test.packet, reply, test.expected)
+		}
+		if pc := client.command("p5"); pc != gdbHex16(test.pc) {
+			t.Errorf("Current PC after %s: %s; expected: %s", test.packet, pc, gdbHex16(test.pc))
+		}
+		client.command("z" + test.packet[1:])
+		if len(emu.Watchpoints()) != 0 {
+			t.Errorf("Current watchpoints after removing %s: %v; expected: none", test.packet, emu.Watchpoints())
+		}
+		client.close()
+	}
+}
+
+func TestGDBInterrupt(t *testing.T) {
+	client := connectGDB(t, loadLoopProgram())
+	// an endless JR -2
+	client.command("M100,2:18fe")
+	fmt.Fprintf(client.conn, "$c#%02x", gdbChecksum("c"))
+	client.reader.ReadByte()
+	client.conn.Write([]byte{gdbInterrupt})
+	if reply := client.reply(); reply != "S02" {
+		t.Errorf("Current reply to an interrupt: %q; expected: \"S02\"", reply)
+	}
+	if pc := client.command("p5"); pc != "0001" {
+		t.Errorf("Current PC after an interrupt: %s; expected: 0001", pc)
+	}
+}
+
+func TestGDBNoAckMode(t *testing.T) {
+	client := connectGDB(t, loadLoopProgram())
+	// the next packet is sent before the reply, the stub reads it while answering
+	fmt.Fprintf(client.conn, "$QStartNoAckMode#%02x$qC#%02x", gdbChecksum("QStartNoAckMode"), gdbChecksum("qC"))
+	if ack, err := client.reader.ReadByte(); err != nil || ack != '+' {
+		t.Fatalf("Current ack of QStartNoAckMode: %q, %v; expected: '+'", ack, err)
+	}
+	if reply := client.reply(); reply != "OK" {
+		t.Fatalf("Current reply to QStartNoAckMode: %q; expected: \"OK\"", reply)
+	}
+	if c, err := client.reader.ReadByte(); err != nil || c != '$' {
+		t.Fatalf("Current first byte after qC: %q, %v; expected: '$'", c, err)
+	}
+	client.reader.UnreadByte()
+	if reply := client.reply(); reply != "QC1" {
+		t.Errorf("Current reply to qC: %q; expected: \"QC1\"", reply)
+	}
+
+	tests := []struct {
+		packet   string
+		expected string
+	}{
+		{"p5", "0001"},
+		{"s", "S05"},
+		{"p5", "0101"},
+		// a wrong checksum is not asked for again any more
+		{"m100,1", "05"},
+	}
+	for i, test := range tests {
+		checksum := gdbChecksum(test.packet)
+		if i == len(tests)-1 {
+			checksum++
+		}
+		fmt.Fprintf(client.conn, "$%s#%02x", test.packet, checksum)
+		// the reply comes without an ack before it
+		if c, err := client.reader.ReadByte(); err != nil || c != '$' {
+			t.Fatalf("Current first byte after %s: %q, %v; expected: '$'", test.packet, c, err)
+		}
+		client.reader.UnreadByte()
+		if reply := client.reply(); reply != test.expected {
+			t.Errorf("Current reply to %s: %q; expected: %q", test.packet, reply, test.expected)
+		}
+	}
+}
+
+// A packet sent while the target runs is answered after the stop reply.
+func TestGDBPacketDuringContinue(t *testing.T) {
+	client := connectGDB(t, loadLoopProgram())
+	// an endless JR -2
+	client.command("M100,2:18fe")
+	client.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(client.conn, "$c#%02x", gdbChecksum("c"))
+	fmt.Fprintf(client.conn, "$p5#%02x", gdbChecksum("p5"))
+	client.conn.Write([]byte{gdbInterrupt})
+	for _, packet := range []string{"c", "p5"} {
+		if ack, err := client.reader.ReadByte(); err != nil || ack != '+' {
+			t.Fatalf("Current ack of %s: %q, %v; expected: '+'", packet, ack, err)
+		}
+	}
+
+	if reply := client.reply(); reply != "S02" {
+		t.Errorf("Current reply to an interrupt: %q; expected: \"S02\"", reply)
+	}
+	if reply := client.reply(); reply != "0001" {
+		t.Errorf("Current reply to p5 sent while running: %q; expected: \"0001\"", reply)
+	}
+}
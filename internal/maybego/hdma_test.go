@@ -0,0 +1,170 @@
+package maybego
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fillDMASource(src uint16, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i + 1)
+		Write(src+uint16(i), data[i])
+	}
+	Write(HDMA1, byte(src>>8))
+	Write(HDMA2, byte(src))
+	Write(HDMA3, 0x01) // 0x8100
+	Write(HDMA4, 0x00)
+	return data
+}
+
+func vramBytes(bank byte, adr uint16, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = vramRead(bank, adr+uint16(i))
+	}
+	return data
+}
+
+// Runs the PPU through one visible line, which starts an HBlank.
+func runLine(emu *Emulator) {
+	Write(LY, 0)
+	Write(STAT, 0x02)
+	emu.ppu.dots = 0
+	for range MODE0_END / 4 {
+		emu.ppu.Render(1)
+	}
+}
+
+func TestGeneralPurposeDMA(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+	data := fillDMASource(0xC000, 0x30)
+	Write(VBK, 1)
+	emu.cpu.reg.PC = 0xC100
+
+	Write(HDMA5, 0x02)
+	if !bytes.Equal(vramBytes(1, 0x8100, 0x30), data) {
+		t.Errorf("Got VRAM %x, expected %x", vramBytes(1, 0x8100, 0x30), data)
+	}
+	if vramRead(0, 0x8100) != 0 {
+		t.Errorf("DMA wrote VRAM bank 0, expected only the selected bank 1")
+	}
+	if Read(HDMA5) != 0xFF || Read(HDMA1) != 0xFF {
+		t.Errorf("Got HDMA5 %x, HDMA1 %x, expected FF FF", Read(HDMA5), Read(HDMA1))
+	}
+
+	stall := 0
+	for emu.FetchDecodeExec() == 1 && emu.cpu.reg.PC == 0xC100 {
+		stall++
+	}
+	if stall != 3*8 {
+		t.Errorf("CPU waited %d cycles, expected %d", stall, 3*8)
+	}
+}
+
+func TestGeneralPurposeDMADoubleSpeed(t *testing.T) {
+	loadCgbRom(t, 0x80, ModelAuto)
+	Memory[KEY1] |= 0x80
+	fillDMASource(0xC000, 0x10)
+
+	Write(HDMA5, 0x00)
+	if cgb.hdma.stall != 16 {
+		t.Errorf("Current stall: %d; expected: %d", cgb.hdma.stall, 16)
+	}
+}
+
+func TestHBlankDMA(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+	data := fillDMASource(0xD000, 0x30)
+
+	Write(HDMA5, 0x82)
+	if Read(HDMA5) != 0x02 {
+		t.Errorf("Current HDMA5: %x; expected: %x", Read(HDMA5), 0x02)
+	}
+	if vramRead(0, 0x8100) != 0 {
+		t.Error("HBlank DMA copied before the HBlank")
+	}
+
+	for line, expected := range []byte{0x01, 0x00, 0xFF} {
+		runLine(emu)
+		if Read(HDMA5) != expected {
+			t.Errorf("Line %d: current HDMA5: %x; expected: %x", line, Read(HDMA5), expected)
+		}
+		copied := (line + 1) * 0x10
+		if !bytes.Equal(vramBytes(0, 0x8100, copied), data[:copied]) {
+			t.Errorf("Line %d: got VRAM %x, expected %x", line, vramBytes(0, 0x8100, copied), data[:copied])
+		}
+	}
+}
+
+func TestHBlankDMACancel(t *testing.T) {
+	emu := loadCgbRom(t, 0x80, ModelAuto)
+	fillDMASource(0xC000, 0x30)
+
+	Write(HDMA5, 0x82)
+	runLine(emu)
+	Write(HDMA5, 0x00)
+	if Read(HDMA5) != 0x81 {
+		t.Errorf("Current HDMA5: %x; expected: %x", Read(HDMA5), 0x81)
+	}
+
+	runLine(emu)
+	if vramRead(0, 0x8110) != 0 {
+		t.Error("Cancelled HBlank DMA kept copying")
+	}
+}
+
+// Runs every ROM below testdata/cgb in CGB mode. They report their result
+// like the mooneye ROMs, see mooneyeResult.
+func TestCgbRoms(t *testing.T) {
+	dir := filepath.Join("testdata", "cgb")
+	var roms []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && (filepath.Ext(path) == ".gb" || filepath.Ext(path) == ".gbc") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	if len(roms) == 0 {
+		t.Skip("no ROMs in testdata/cgb")
+	}
+	t.Cleanup(func() { cgb = cgbMemory{} })
+
+	var matrix strings.Builder
+	for _, rom := range roms {
+		name, _ := filepath.Rel(dir, rom)
+		result := "timeout"
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			emu := loadProgram(data, ModelCGB)
+
+			state := mooneyeRunning
+			emu.GetCPU().SetDebugBreakpoint(func() {
+				state = mooneyeResult(emu.cpu.reg)
+			})
+			for state == mooneyeRunning && emu.GetCPUState().cycles < mooneyeTimeout {
+				emu.RunFrame()
+			}
+
+			switch state {
+			case mooneyePassed:
+				result = "pass"
+			case mooneyeFailed:
+				result = "FAIL"
+				t.Fail()
+			default:
+				t.Errorf("Timed out after %d cycles", mooneyeTimeout)
+			}
+		})
+		fmt.Fprintf(&matrix, "%-7s %s\n", result, name)
+	}
+	t.Logf("\n%s", matrix.String())
+}
@@ -0,0 +1,158 @@
+package maybego
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const DefaultTurboRate uint = 2
+
+// A sequence of inputs played back one step after another.
+type Macro []macroStep
+
+type macroStep struct {
+	buttons    byte // pressed bits, unlike the joypad registers
+	directions byte
+	frames     int
+}
+
+var macroButtons = map[string]Button{
+	"a": ButtonA, "b": ButtonB, "select": ButtonSelect, "start": ButtonStart,
+}
+
+var macroDirections = map[string]Direction{
+	"up": DirectionUp, "down": DirectionDown, "left": DirectionLeft, "right": DirectionRight,
+}
+
+// Parses a comma separated list of steps, e.g. "start, wait 10, a".
+// A step holds one or more inputs joined with "+" for one frame, or for n
+// frames when followed by ":n" (e.g. "right+b:30"). "wait n" holds nothing for n frames.
+func ParseMacro(text string) (Macro, error) {
+	var macro Macro
+	for _, part := range strings.Split(text, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		step := macroStep{frames: 1}
+
+		if wait, ok := strings.CutPrefix(part, "wait "); ok {
+			frames, err := strconv.Atoi(strings.TrimSpace(wait))
+			if err != nil || frames < 1 {
+				return nil, fmt.Errorf("invalid wait in macro step %q", part)
+			}
+			step.frames = frames
+			macro = append(macro, step)
+			continue
+		}
+
+		inputs, frames, found := strings.Cut(part, ":")
+		if found {
+			n, err := strconv.Atoi(strings.TrimSpace(frames))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid frame count in macro step %q", part)
+			}
+			step.frames = n
+		}
+		for _, name := range strings.Split(inputs, "+") {
+			name = strings.TrimSpace(name)
+			if b, ok := macroButtons[name]; ok {
+				step.buttons |= 1 << b
+			} else if d, ok := macroDirections[name]; ok {
+				step.directions |= 1 << d
+			} else {
+				return nil, fmt.Errorf("unknown input %q in macro step %q", name, part)
+			}
+		}
+		macro = append(macro, step)
+	}
+	return macro, nil
+}
+
+// Sets how many frames a turbo button stays pressed and released.
+func (emu *Emulator) SetTurboRate(frames uint) {
+	emu.turbo_rate = max(frames, 1)
+}
+
+// Holds the button down every other turbo period while pressed.
+func (emu *Emulator) PressTurbo(b Button) {
+	if emu.held_turbo == [4]int{} {
+		// the rest of this frame is pressed as well, input may only be seen at the next one
+		emu.turbo_start = emu.input_frames + 1
+	}
+	emu.held_turbo[b]++
+	emu.applyInput()
+}
+
+func (emu *Emulator) ReleaseTurbo(b Button) {
+	if emu.held_turbo[b] == 0 {
+		return
+	}
+	emu.held_turbo[b]--
+	emu.applyInput()
+}
+
+// Starts a macro, replacing one that is still running.
+func (emu *Emulator) PlayMacro(macro Macro) {
+	if len(macro) == 0 {
+		return
+	}
+	emu.macro = macro
+	emu.macro_step = 0
+	emu.macro_frames = 0
+	emu.applyInput()
+}
+
+func (emu *Emulator) turboPressed() bool {
+	if emu.input_frames < emu.turbo_start {
+		return true
+	}
+	return (emu.input_frames-emu.turbo_start)/emu.turbo_rate%2 == 0
+}
+
+// Called before every frame, so turbo and macros only depend on the frame count.
+func (emu *Emulator) nextInputFrame() {
+	emu.input_frames++
+
+	if emu.macro != nil {
+		emu.macro_frames++
+		if emu.macro_frames >= emu.macro[emu.macro_step].frames {
+			emu.macro_step++
+			emu.macro_frames = 0
+		}
+		if emu.macro_step >= len(emu.macro) {
+			emu.macro = nil
+		}
+	}
+
+	emu.applyInput()
+}
+
+// Combines held inputs, turbo and the current macro step into the joypad state.
+func (emu *Emulator) applyInput() {
+	var buttons, directions byte
+	for b, held := range emu.held_buttons {
+		if held > 0 {
+			buttons |= 1 << b
+		}
+	}
+	for d, held := range emu.held_directions {
+		if held > 0 {
+			directions |= 1 << d
+		}
+	}
+	if emu.turboPressed() {
+		for b, held := range emu.held_turbo {
+			if held > 0 {
+				buttons |= 1 << b
+			}
+		}
+	}
+	if emu.macro != nil {
+		buttons |= emu.macro[emu.macro_step].buttons
+		directions |= emu.macro[emu.macro_step].directions
+	}
+
+	joy := emu.input()
+	joy.buttons = ^buttons & 0xF
+	joy.directions = ^directions & 0xF
+	emu.joypad.update()
+}
@@ -0,0 +1,154 @@
+package maybego
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMacro(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Macro
+	}{
+		{"a", Macro{{buttons: 1 << ButtonA, frames: 1}}},
+		{"start, wait 10, a", Macro{
+			{buttons: 1 << ButtonStart, frames: 1},
+			{frames: 10},
+			{buttons: 1 << ButtonA, frames: 1},
+		}},
+		{"Right+B:30", Macro{{buttons: 1 << ButtonB, directions: 1 << DirectionRight, frames: 30}}},
+	}
+
+	for _, test := range tests {
+		macro, err := ParseMacro(test.text)
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if !slices.Equal(macro, test.expected) {
+			t.Errorf("%q: got %v; expected: %v", test.text, macro, test.expected)
+		}
+	}
+}
+
+func TestParseMacroErrors(t *testing.T) {
+	for _, text := range []string{"", "a, x", "wait", "wait 0", "a:", "a:-1"} {
+		if _, err := ParseMacro(text); err == nil {
+			t.Errorf("%q was accepted", text)
+		}
+	}
+}
+
+func TestTurbo(t *testing.T) {
+	emu := NewEmulator(logger)
+	emu.SetTurboRate(2)
+	emu.PressTurbo(ButtonA)
+
+	expected := []byte{0xE, 0xE, 0xE, 0xF, 0xF, 0xE}
+	for frame, buttons := range expected {
+		if emu.joypad.buttons != buttons {
+			t.Errorf("Frame %d: current buttons: %x; expected: %x", frame, emu.joypad.buttons, buttons)
+		}
+		emu.nextInputFrame()
+	}
+
+	emu.ReleaseTurbo(ButtonA)
+	if emu.joypad.buttons != 0xF {
+		t.Errorf("Current buttons: %x; expected: %x", emu.joypad.buttons, 0xF)
+	}
+}
+
+func TestTurboKeepsHeldButtons(t *testing.T) {
+	emu := NewEmulator(logger)
+	emu.PressButton(ButtonA)
+	emu.PressTurbo(ButtonA)
+
+	for frame := range 4 {
+		if emu.joypad.buttons != 0xE {
+			t.Errorf("Frame %d: current buttons: %x; expected: %x", frame, emu.joypad.buttons, 0xE)
+		}
+		emu.nextInputFrame()
+	}
+}
+
+func TestMacro(t *testing.T) {
+	emu := NewEmulator(logger)
+	macro, err := ParseMacro("start, wait 2, up+a:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu.PlayMacro(macro)
+
+	expected := []struct{ buttons, directions byte }{
+		{0x7, 0xF},
+		{0xF, 0xF},
+		{0xF, 0xF},
+		{0xE, 0xB},
+		{0xE, 0xB},
+		{0xF, 0xF},
+	}
+	for frame, input := range expected {
+		if emu.joypad.buttons != input.buttons || emu.joypad.directions != input.directions {
+			t.Errorf("Frame %d: current buttons: %x directions: %x; expected: %x %x",
+				frame, emu.joypad.buttons, emu.joypad.directions, input.buttons, input.directions)
+		}
+		emu.nextInputFrame()
+	}
+}
+
+func TestKeyMapTurboAndMacros(t *testing.T) {
+	emu := NewEmulator(logger)
+	config := DefaultConfig()
+	config.Macros = map[string]string{"Q": "select"}
+	keys := config.Keys.keyMap()
+	macros, err := config.macros()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.macros = macros
+
+	keys.press(emu, "F")
+	if emu.joypad.buttons != 0xE {
+		t.Errorf("Current buttons: %x; expected: %x", emu.joypad.buttons, 0xE)
+	}
+	keys.release(emu, "F")
+	if !keys.press(emu, "Q") || emu.joypad.buttons != 0xB {
+		t.Errorf("Current buttons: %x; expected: %x", emu.joypad.buttons, 0xB)
+	}
+
+	// a bad macro is reported, the others are kept
+	config.Macros = map[string]string{"Q": "jump", "W": "select"}
+	macros, err = config.macros()
+	if err == nil {
+		t.Error("Invalid macro was accepted")
+	}
+	if _, ok := macros["W"]; !ok || len(macros) != 1 {
+		t.Errorf("Current macros: %v; expected only the one for W", macros)
+	}
+}
+
+// Turbo only depends on the frame count, so a movie records it like held input.
+func TestMovieRecordsTurbo(t *testing.T) {
+	emu := loadProgram(joypadSumRom(), ModelAuto)
+	emu.SetTurboRate(1)
+
+	if err := emu.StartRecording(false); err != nil {
+		t.Fatal(err)
+	}
+	emu.PressTurbo(ButtonB)
+	for range 6 {
+		emu.RunFrame()
+	}
+	emu.ReleaseTurbo(ButtonB)
+	movie := emu.StopMovie()
+
+	pressed := 0
+	for _, input := range movie.inputs {
+		if input&0xF == 0xD {
+			pressed++
+		}
+	}
+	if pressed != 3 {
+		t.Errorf("Recorded %d frames with B pressed; expected: %d (inputs %x)", pressed, 3, movie.inputs)
+	}
+}
@@ -26,37 +26,44 @@ const (
 )
 
 func NewJoypad() *Joypad {
-	Write(0xFF00, 0x3F)
-	return &Joypad{prev_joypad: Read(JOYP), directions: 0xF, buttons: 0xF}
+	joy := &Joypad{prev_joypad: 0xFF, directions: 0xF, buttons: 0xF}
+	Memory[JOYP] = 0xFF // nothing selected
+	return joy
 }
 
-func (joy *Joypad) updateControls() {
-	new_joypad := Read(JOYP) & 0xF0
-	directions_enabled := (new_joypad & 0x30) == 0x20
-	buttons_enabled := (new_joypad & 0x30) == 0x10
-
-	if !directions_enabled && !buttons_enabled {
-		joy.prev_joypad = new_joypad | 0xF // all buttons disabled
-		Write(JOYP, joy.prev_joypad)
-		return
+// Handles a write to JOYP from the bus, only the select bits are writable.
+func (joy *Joypad) write(val byte) {
+	Memory[JOYP] = Memory[JOYP]&0xCF | val&0x30
+	if sgb.enabled {
+		sgbWrite(val)
 	}
+	joy.update()
+}
 
-	if directions_enabled {
-		new_joypad |= joy.directions
-	} else {
-		new_joypad |= joy.buttons
+// Recomputes the input lines of JOYP, called whenever the selection or the pressed inputs change.
+// Selecting both groups at once gives the pressed inputs of either.
+// The interrupt is requested when a line goes from high to low.
+// With several SGB joypads, only the first one has inputs and selecting
+// neither group reads the number of the current one.
+func (joy *Joypad) update() {
+	selected := Memory[JOYP] & 0x30
+	lines := byte(0xF)
+	if selected&0x10 == 0 && sgb.player == 0 {
+		lines &= joy.directions
+	}
+	if selected&0x20 == 0 && sgb.player == 0 {
+		lines &= joy.buttons
+	}
+	if selected == 0x30 && sgb.players > 1 {
+		lines = 0xF - sgb.player
 	}
 
-	bitmask := byte(0x1)
-	for range 4 {
-		if new_joypad&bitmask == 0 {
-			RequestInterrupt(4)
-		}
-		bitmask <<= 1
+	if joy.prev_joypad&^lines&0xF != 0 {
+		RequestInterrupt(4)
 	}
 
-	joy.prev_joypad = new_joypad
-	Write(JOYP, new_joypad)
+	joy.prev_joypad = 0xC0 | selected | lines
+	Memory[JOYP] = joy.prev_joypad
 }
 
 func (joy *Joypad) setButton(b Button) {
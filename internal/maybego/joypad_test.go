@@ -0,0 +1,89 @@
+package maybego
+
+import (
+	"testing"
+)
+
+func TestJoypadSelect(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	emu.PressButton(ButtonStart)
+	emu.PressDirection(DirectionLeft)
+
+	tests := []struct {
+		name      string
+		selection byte
+		expected  byte
+	}{
+		{"nothing", 0x30, 0xFF},
+		{"directions", 0x20, 0xED},
+		{"buttons", 0x10, 0xD7},
+		{"both", 0x00, 0xC5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Write(JOYP, test.selection|0x0F)
+			actual := Read(JOYP)
+			if actual != test.expected {
+				t.Errorf("Current JOYP: %x; expected: %x", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestJoypadBothGroupsPressSameLine(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	Write(JOYP, 0x00)
+
+	// A and Right share line 0, it stays low until both are released
+	emu.PressButton(ButtonA)
+	emu.PressDirection(DirectionRight)
+	emu.ReleaseButton(ButtonA)
+	if Read(JOYP)&0x1 != 0 {
+		t.Errorf("Current JOYP: %x; expected line 0 to be low", Read(JOYP))
+	}
+	emu.ReleaseDirection(DirectionRight)
+	if Read(JOYP) != 0xCF {
+		t.Errorf("Current JOYP: %x; expected: %x", Read(JOYP), 0xCF)
+	}
+}
+
+func TestJoypadInterrupt(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	interrupted := func() bool {
+		requested := Read(IF)&(1<<4) != 0
+		Write(IF, 0)
+		return requested
+	}
+
+	Write(JOYP, 0x10)
+	emu.PressDirection(DirectionUp)
+	if interrupted() {
+		t.Error("Interrupt requested for an input that is not selected")
+	}
+
+	emu.PressButton(ButtonB)
+	if !interrupted() {
+		t.Error("No interrupt requested when B was pressed")
+	}
+	emu.applyInput()
+	Write(JOYP, 0x10)
+	if interrupted() {
+		t.Error("Interrupt requested again while B is held")
+	}
+
+	// selecting the directions pulls the line of Up low
+	Write(JOYP, 0x00)
+	if !interrupted() {
+		t.Error("No interrupt requested when selecting a group with a held input")
+	}
+
+	emu.ReleaseButton(ButtonB)
+	emu.ReleaseDirection(DirectionUp)
+	if interrupted() {
+		t.Error("Interrupt requested when inputs were released")
+	}
+}
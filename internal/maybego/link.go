@@ -0,0 +1,241 @@
+package maybego
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// How far one emulator of a LocalLink may run ahead of the other.
+const LINK_SYNC_CYCLES uint = SERIAL_TRANSFER_CYCLES
+
+// How long a transfer waits for the other side of a network link.
+const linkTimeout = time.Second
+
+// The other end of a link cable. Bytes are exchanged whole, once the side
+// with the internal clock finished shifting them.
+type LinkCable interface {
+	// Sends the byte of a transfer clocked by this side, returns the byte of the other side.
+	// Never blocks: until the other side answered it returns false, and the serial
+	// port calls it again with the same byte on the next step.
+	exchange(out byte) (byte, bool)
+	// Handles transfers clocked by the other side, called every step.
+	poll(serial *Serial)
+	Close() error
+}
+
+// Connects the serial port to a link cable, nil disconnects it.
+func (emu *Emulator) ConnectLink(cable LinkCable) {
+	emu.serial.link = cable
+}
+
+// Two emulators in one process, connected by a link cable and run in lockstep.
+// Memory, the CGB banks, the SGB and the framebuffer are shared by every emulator of the process,
+// so the link swaps them in for the emulator that runs and parks the other one.
+type LocalLink struct {
+	emus        [2]*Emulator
+	active      int
+	cycles      [2]uint
+	memory      [2][65536]byte
+	framebuffer [2][160 * 144]uint16
+	cgb         [2]cgbMemory
+	sgb         [2]sgbState
+}
+
+type localCable struct {
+	link *LocalLink
+	side int
+}
+
+// Creates two emulators of the given model with the given ROMs and links them.
+func NewLocalLink(logger *Logger, model Model, rom_a *[]byte, rom_b *[]byte) *LocalLink {
+	link := &LocalLink{}
+	for i, rom := range []*[]byte{rom_a, rom_b} {
+		Memory = [65536]byte{}
+		emu := NewEmulator(logger)
+		emu.SetModel(model)
+		emu.LoadRom(rom)
+		emu.ConnectLink(&localCable{link: link, side: i})
+		link.emus[i] = emu
+		link.memory[i] = Memory
+		link.framebuffer[i] = framebuffer
+		link.cgb[i] = cgb
+		link.sgb[i] = sgb
+	}
+	link.active = 1
+	link.Switch(0)
+	return link
+}
+
+// Makes the emulator of the given side (0 or 1) the one in the globals, e.g. to
+// read its memory or frame, and returns it.
+func (link *LocalLink) Switch(side int) *Emulator {
+	if side != link.active {
+		link.memory[link.active] = Memory
+		link.framebuffer[link.active] = framebuffer
+		link.cgb[link.active] = cgb
+		link.sgb[link.active] = sgb
+		Memory = link.memory[side]
+		framebuffer = link.framebuffer[side]
+		cgb = link.cgb[side]
+		sgb = link.sgb[side]
+		busJoypad = link.emus[side].joypad
+		busWatch = link.emus[side].watch
+		link.active = side
+	}
+	return link.emus[side]
+}
+
+// Runs both emulators until the first one finished a frame. The one that is
+// behind always runs, until it is LINK_SYNC_CYCLES ahead of the other.
+// Afterwards the first emulator is switched in.
+func (link *LocalLink) RunFrame() bool {
+	if !link.emus[0].rom_loaded || !link.emus[1].rom_loaded {
+		return false
+	}
+
+	for {
+		side := 0
+		if link.cycles[1] < link.cycles[0] {
+			side = 1
+		}
+		emu := link.Switch(side)
+		limit := link.cycles[1-side] + LINK_SYNC_CYCLES
+
+		for link.cycles[side] < limit {
+			before := emu.cpu.clk.cycles
+			frame_ready := emu.Run()
+			// a reset starts counting from 0 again
+			if emu.cpu.clk.cycles > before {
+				link.cycles[side] += emu.cpu.clk.cycles - before
+			} else {
+				link.cycles[side]++
+			}
+			if frame_ready && side == 0 {
+				return true
+			}
+		}
+	}
+}
+
+func (cable *localCable) exchange(out byte) (byte, bool) {
+	peer := 1 - cable.side
+	return cable.link.emus[peer].serial.receive(&cable.link.memory[peer], out), true
+}
+
+// The other side finishes transfers it clocks itself in exchange.
+func (cable *localCable) poll(serial *Serial) {}
+
+func (cable *localCable) Close() error {
+	return nil
+}
+
+// Message kinds of the network link, each message is the kind followed by one byte.
+const (
+	linkTransfer byte = 'T' // a transfer clocked by the sender
+	linkReply    byte = 'R' // the byte shifted out in return
+)
+
+// A link cable to another process over TCP.
+type NetLink struct {
+	conn     net.Conn
+	messages chan [2]byte
+	closed   bool
+	// the transfer clocked by this side, waiting for its reply until deadline
+	pending  bool
+	deadline time.Time
+	replied  bool
+	reply    byte
+}
+
+// Waits for the other side to connect to addr, e.g. "localhost:5000".
+func ListenLink(addr string) (*NetLink, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newNetLink(conn), nil
+}
+
+// Connects to a side waiting in ListenLink.
+func DialLink(addr string) (*NetLink, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newNetLink(conn), nil
+}
+
+func newNetLink(conn net.Conn) *NetLink {
+	link := &NetLink{conn: conn, messages: make(chan [2]byte, 16)}
+	go link.read()
+	return link
+}
+
+// Passes received messages to the emulator, which must not be touched from here.
+func (link *NetLink) read() {
+	defer close(link.messages)
+	for {
+		var message [2]byte
+		if _, err := io.ReadFull(link.conn, message[:]); err != nil {
+			return
+		}
+		link.messages <- message
+	}
+}
+
+func (link *NetLink) send(kind byte, val byte) {
+	// a lost connection shows up as a closed messages channel
+	link.conn.Write([]byte{kind, val})
+}
+
+// The reply is collected by poll, so the emulator keeps running while the other side answers.
+func (link *NetLink) exchange(out byte) (byte, bool) {
+	if !link.pending {
+		link.send(linkTransfer, out)
+		link.pending, link.replied = true, false
+		link.deadline = time.Now().Add(linkTimeout)
+	}
+
+	switch {
+	case link.replied:
+		link.pending = false
+		return link.reply, true
+	case link.closed || time.Now().After(link.deadline):
+		link.pending = false
+		return 0xFF, true
+	}
+	return 0, false
+}
+
+func (link *NetLink) poll(serial *Serial) {
+	for {
+		select {
+		case message, ok := <-link.messages:
+			if !ok {
+				link.closed = true
+				return
+			}
+			switch {
+			case message[0] == linkTransfer:
+				// if both sides use the internal clock, receive answers 0xFF
+				link.send(linkReply, serial.receive(&Memory, message[1]))
+			case link.pending:
+				link.reply, link.replied = message[1], true
+			}
+			// replies that arrive after their transfer timed out are dropped
+		default:
+			return
+		}
+	}
+}
+
+func (link *NetLink) Close() error {
+	return link.conn.Close()
+}
@@ -0,0 +1,164 @@
+package maybego
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// Sends val over serial and stores the received byte at 0xC000.
+// control is 0x81 for the internal clock or 0x80 to wait for the other side.
+func serialRom(val byte, control byte) []byte {
+	return programRom(
+		0xF3,      // DI
+		0x3E, val, // LD A, val
+		0xE0, 0x01, // LDH (SB), A
+		0x3E, control, // LD A, control
+		0xE0, 0x02, // LDH (SC), A
+		0xF0, 0x02, // LDH A, (SC)
+		0xCB, 0x7F, // BIT 7, A
+		0x20, 0xFA, // JR NZ, -6
+		0xF0, 0x01, // LDH A, (SB)
+		0xEA, 0x00, 0xC0, // LD (0xC000), A
+		0x18, 0xFE, // JR -2
+	)
+}
+
+func TestLocalLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		slave      []byte
+		expected_a byte
+		expected_b byte
+	}{
+		{"slave ready", serialRom(0x99, 0x80), 0x99, 0x42},
+		{"slave not ready", serialRom(0x99, 0x00), 0xFF, 0x99},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			master := serialRom(0x42, 0x81)
+			link := NewLocalLink(logger, ModelAuto, &master, &test.slave)
+			for range 3 {
+				link.RunFrame()
+			}
+
+			link.Switch(0)
+			if Read(0xC000) != test.expected_a {
+				t.Errorf("Master received: %x; expected: %x", Read(0xC000), test.expected_a)
+			}
+			if Read(IF)&0x8 == 0 {
+				t.Errorf("Wrong IF of master. Got %.2X, expected serial interrupt", Read(IF))
+			}
+
+			link.Switch(1)
+			if Read(0xC000) != test.expected_b {
+				t.Errorf("Slave received: %x; expected: %x", Read(0xC000), test.expected_b)
+			}
+		})
+	}
+}
+
+func TestNetLinkInternalClock(t *testing.T) {
+	emu := loadProgram(serialRom(0x42, 0x81), ModelAuto)
+
+	local, remote := net.Pipe()
+	emu.ConnectLink(newNetLink(local))
+	defer emu.serial.link.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		message := make([]byte, 2)
+		remote.Read(message)
+		remote.Write([]byte{linkReply, 0x99})
+		received <- message
+	}()
+
+	var message []byte
+	for message == nil {
+		emu.RunFrame()
+		select {
+		case message = <-received:
+		default:
+		}
+	}
+	// the reply is picked up on the next steps
+	for range 2 {
+		emu.RunFrame()
+	}
+	if message[0] != linkTransfer || message[1] != 0x42 {
+		t.Errorf("Sent message: %q; expected: %q", message, []byte{linkTransfer, 0x42})
+	}
+	if Read(0xC000) != 0x99 {
+		t.Errorf("Received: %x; expected: %x", Read(0xC000), 0x99)
+	}
+}
+
+func TestNetLinkWaitsWithoutBlocking(t *testing.T) {
+	emu := loadProgram(serialRom(0x42, 0x81), ModelAuto)
+
+	local, remote := net.Pipe()
+	link := newNetLink(local)
+	emu.ConnectLink(link)
+	defer link.Close()
+
+	// the other side takes the transfer but never answers
+	go io.Copy(io.Discard, remote)
+
+	start := time.Now()
+	for range 5 {
+		emu.RunFrame()
+	}
+	if elapsed := time.Since(start); elapsed >= linkTimeout {
+		t.Errorf("Current time for 5 frames: %v; expected: less than %v", elapsed, linkTimeout)
+	}
+	if Read(SC)&0x80 == 0 || Read(0xC000) != 0 {
+		t.Errorf("Current SC: %x, received: %x; expected: transfer still running", Read(SC), Read(0xC000))
+	}
+
+	// without a reply the transfer ends with 0xFF after the timeout
+	link.deadline = time.Now()
+	emu.RunFrame()
+	if Read(0xC000) != 0xFF {
+		t.Errorf("Received after the timeout: %x; expected: %x", Read(0xC000), 0xFF)
+	}
+}
+
+func TestNetLinkExternalClock(t *testing.T) {
+	emu := loadProgram(serialRom(0x99, 0x80), ModelAuto)
+
+	local, remote := net.Pipe()
+	emu.ConnectLink(newNetLink(local))
+	defer emu.serial.link.Close()
+
+	// clock a transfer once the emulator is waiting for one
+	emu.RunFrame()
+	received := make(chan []byte, 1)
+	go func() {
+		remote.Write([]byte{linkTransfer, 0x42})
+		message := make([]byte, 2)
+		remote.Read(message)
+		received <- message
+	}()
+
+	var message []byte
+	for message == nil {
+		emu.RunFrame()
+		select {
+		case message = <-received:
+		default:
+		}
+	}
+	emu.RunFrame()
+
+	if message[0] != linkReply || message[1] != 0x99 {
+		t.Errorf("Reply: %q; expected: %q", message, []byte{linkReply, 0x99})
+	}
+	if Read(0xC000) != 0x42 {
+		t.Errorf("Received: %x; expected: %x", Read(0xC000), 0x42)
+	}
+	if Read(IF)&0x8 == 0 {
+		t.Errorf("Wrong IF. Got %.2X, expected serial interrupt", Read(IF))
+	}
+}
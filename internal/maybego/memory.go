@@ -1,15 +1,92 @@
 package maybego
 
+import (
+	"strconv"
+	"strings"
+)
+
 var Memory [65536]byte
 
+// The joypad of the running emulator, JOYP writes go to it instead of memory.
+var busJoypad *Joypad
+
 func InitMemory() {
 	Memory[0xFF00] = 0xCF // init joypad input
 }
 
 func Read(adr uint16) byte {
+	val := DebugRead(adr)
+	if busWatch != nil && busWatch.active() {
+		busWatch.read(adr, val)
+	}
+	return val
+}
+
+// Reads what the CPU would read, without any side effects, for debuggers.
+func DebugRead(adr uint16) byte {
+	if cgb.enabled {
+		return cgbRead(adr)
+	}
 	return Memory[adr]
 }
 
+// Changes the byte the CPU reads at adr, for debuggers. Unlike Write, this
+// has no side effects: JOYP selects nothing and HDMA5 starts no transfer.
+func DebugWrite(adr uint16, val byte) {
+	if cgb.enabled {
+		if bank, offset := cgbBank(adr); bank != nil {
+			bank[offset] = val
+			return
+		}
+	}
+	Memory[adr] = val
+}
+
+// Returns the short name of the memory region adr is in, e.g. "VRAM".
+func MemoryRegion(adr uint16) string {
+	switch {
+	case adr < 0x4000:
+		return "ROM0"
+	case adr < 0x8000:
+		return "ROMX"
+	case adr < 0xA000:
+		return "VRAM"
+	case adr < 0xC000:
+		return "SRAM"
+	case adr < 0xE000:
+		return "WRAM"
+	case adr < 0xFE00:
+		return "ECHO"
+	case adr < 0xFEA0:
+		return "OAM"
+	case adr < 0xFF00:
+		return "----"
+	case adr < 0xFF80:
+		return "IO"
+	case adr < 0xFFFF:
+		return "HRAM"
+	}
+	return "IE"
+}
+
 func Write(adr uint16, val byte) {
+	if busWatch != nil && busWatch.active() {
+		busWatch.write(adr, DebugRead(adr), val)
+	}
+	if adr == JOYP && busJoypad != nil {
+		busJoypad.write(val)
+		return
+	}
+	if cgb.enabled {
+		cgbWrite(adr, val)
+		return
+	}
 	Memory[adr] = val
 }
+
+// Parses a hex number with or without a 0x or $ prefix.
+func parseHex(text string, bits int) (uint64, error) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "0x"), "$")
+	return strconv.ParseUint(text, 16, bits)
+}
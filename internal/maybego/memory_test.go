@@ -20,3 +20,53 @@ func TestRead(t *testing.T) {
 		t.Error("Expected Memory[0x5623] to be 0x08")
 	}
 }
+
+func TestDebugWrite(t *testing.T) {
+	Memory = [65536]byte{}
+	NewEmulator(logger)
+
+	// unlike Write, the selection of JOYP stays
+	DebugWrite(JOYP, 0xC0)
+	if DebugRead(JOYP) != 0xC0 {
+		t.Errorf("Current JOYP: %x; expected: %x", DebugRead(JOYP), 0xC0)
+	}
+}
+
+func TestDebugWriteBanks(t *testing.T) {
+	loadCgbRom(t, 0x80, ModelAuto)
+	Write(SVBK, 0x03)
+
+	DebugWrite(0xD000, 0x42)
+	if cgb.wram[3][0] != 0x42 || Memory[0xD000] != 0x00 {
+		t.Errorf("Got bank 3 %x and bank 1 %x, expected 42 0", cgb.wram[3][0], Memory[0xD000])
+	}
+	DebugWrite(HDMA5, 0x00)
+	if DebugRead(HDMA5) != 0x00 || cgb.hdma.stall != 0 {
+		t.Error("DebugWrite started a VRAM DMA")
+	}
+}
+
+func TestMemoryRegion(t *testing.T) {
+	tests := []struct {
+		adr      uint16
+		expected string
+	}{
+		{0x0150, "ROM0"},
+		{0x4000, "ROMX"},
+		{0x9FFF, "VRAM"},
+		{0xA000, "SRAM"},
+		{0xD000, "WRAM"},
+		{0xE000, "ECHO"},
+		{0xFE00, "OAM"},
+		{0xFEA0, "----"},
+		{0xFF40, "IO"},
+		{0xFF80, "HRAM"},
+		{0xFFFF, "IE"},
+	}
+
+	for _, test := range tests {
+		if actual := MemoryRegion(test.adr); actual != test.expected {
+			t.Errorf("Region of %x: %s; expected: %s", test.adr, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+//go:build !headless
+
+package maybego
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Bytes per row of the memory viewer, as many as one tile has.
+const memoryRowSize = 16
+
+// Columns of a row: region, address, the bytes in hex and as ASCII.
+const (
+	memoryHexColumn   = 10
+	memoryASCIIColumn = memoryHexColumn + memoryRowSize*3 + 2
+)
+
+var errEditRunning = errors.New("pause the emulator to edit memory")
+
+// A hex editor for the whole address space. It reads through DebugRead,
+// so looking at memory never changes it.
+type memoryView struct {
+	container *fyne.Container
+	list      *widget.List
+	address   *widget.Entry
+	value     *widget.Entry
+	debug     *debugView
+	window    fyne.Window
+	cursor    uint16
+	// memory at the last step, bytes that differ from it are highlighted
+	previous [65536]byte
+	changed  [65536]bool
+}
+
+// One row of the memory viewer with the tile its bytes form next to it.
+type memoryRow struct {
+	widget.BaseWidget
+	view *memoryView
+	grid *widget.TextGrid
+	tile *canvas.Raster
+	adr  uint16
+}
+
+func createMemoryView(debug *debugView, window fyne.Window) *memoryView {
+	view := &memoryView{debug: debug, window: window}
+	view.list = widget.NewList(
+		func() int { return 0x10000 / memoryRowSize },
+		func() fyne.CanvasObject { return newMemoryRow(view) },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*memoryRow).update(uint16(id * memoryRowSize))
+		},
+	)
+
+	view.address = widget.NewEntry()
+	view.address.SetPlaceHolder("address")
+	view.address.OnSubmitted = func(string) { view.gotoEntry() }
+	view.value = widget.NewEntry()
+	view.value.SetPlaceHolder("value")
+	view.value.OnSubmitted = func(string) { view.setEntry() }
+
+	title := widget.NewLabel("Memory")
+	title.TextStyle.Bold = true
+	controls := container.NewGridWithColumns(4,
+		view.address, widget.NewButton("Go", view.gotoEntry),
+		view.value, widget.NewButton("Set", view.setEntry),
+	)
+	view.container = container.NewBorder(container.NewVBox(title, controls), nil, nil, nil, view.list)
+	view.step()
+	return view
+}
+
+// Remembers the current memory, changes from now on are highlighted.
+func (view *memoryView) step() {
+	for i := range view.previous {
+		val := DebugRead(uint16(i))
+		view.changed[i] = val != view.previous[i]
+		view.previous[i] = val
+	}
+	view.list.Refresh()
+}
+
+// Selects a byte and scrolls to it.
+func (view *memoryView) selectByte(adr uint16) {
+	view.cursor = adr
+	view.address.SetText(fmt.Sprintf("%04X", adr))
+	view.value.SetText(fmt.Sprintf("%02X", DebugRead(adr)))
+	view.list.ScrollTo(widget.ListItemID(adr / memoryRowSize))
+	view.list.Refresh()
+}
+
+func (view *memoryView) gotoEntry() {
+	adr, err := parseHex(view.address.Text, 16)
+	if err != nil {
+		dialog.ShowError(err, view.window)
+		return
+	}
+	view.selectByte(uint16(adr))
+}
+
+func (view *memoryView) setEntry() {
+	if !view.debug.halt {
+		dialog.ShowError(errEditRunning, view.window)
+		return
+	}
+	val, err := parseHex(view.value.Text, 8)
+	if err != nil {
+		dialog.ShowError(err, view.window)
+		return
+	}
+	DebugWrite(view.cursor, byte(val))
+	view.list.Refresh()
+}
+
+func newMemoryRow(view *memoryView) *memoryRow {
+	row := &memoryRow{view: view, grid: widget.NewTextGrid()}
+	row.tile = canvas.NewRasterWithPixels(row.tilePixel)
+	row.tile.SetMinSize(fyne.NewSize(16, 16))
+	row.ExtendBaseWidget(row)
+	return row
+}
+
+func (row *memoryRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewHBox(row.grid, row.tile))
+}
+
+func (row *memoryRow) update(adr uint16) {
+	row.adr = adr
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%-4s %04X ", MemoryRegion(adr), adr)
+	ascii := make([]byte, memoryRowSize)
+	for i := range uint16(memoryRowSize) {
+		val := DebugRead(adr + i)
+		fmt.Fprintf(&text, " %02X", val)
+		ascii[i] = '.'
+		if val >= 0x20 && val < 0x7F {
+			ascii[i] = val
+		}
+	}
+	fmt.Fprintf(&text, "  %s", ascii)
+	row.grid.SetText(text.String())
+
+	changed := &widget.CustomTextGridStyle{BGColor: theme.Color(theme.ColorNameWarning)}
+	selected := &widget.CustomTextGridStyle{BGColor: theme.Color(theme.ColorNameFocus)}
+	for i := range memoryRowSize {
+		var style widget.TextGridStyle
+		switch {
+		case adr+uint16(i) == row.view.cursor:
+			style = selected
+		case row.view.changed[adr+uint16(i)]:
+			style = changed
+		default:
+			continue
+		}
+		row.grid.SetStyleRange(0, memoryHexColumn+i*3+1, 0, memoryHexColumn+i*3+2, style)
+		row.grid.SetStyle(0, memoryASCIIColumn+i, style)
+	}
+	row.tile.Refresh()
+}
+
+// Shows the 16 bytes of the row as a tile, in the colors of the display.
+func (row *memoryRow) tilePixel(x, y, w, h int) color.Color {
+	x, y = x*8/max(w, 1), y*8/max(h, 1)
+	low := DebugRead(row.adr + uint16(y*2))
+	high := DebugRead(row.adr + uint16(y*2+1))
+	pixel := (low>>(7-x))&0x1 | ((high>>(7-x))&0x1)<<1
+	return Palette[pixel]
+}
+
+// Tapping a byte, in hex or ASCII, selects it.
+func (row *memoryRow) Tapped(ev *fyne.PointEvent) {
+	_, col := row.grid.CursorLocationForPosition(ev.Position)
+	var i int
+	switch {
+	case col >= memoryASCIIColumn:
+		i = col - memoryASCIIColumn
+	case col > memoryHexColumn:
+		i = (col - memoryHexColumn - 1) / 3
+	}
+	row.view.selectByte(row.adr + uint16(min(i, memoryRowSize-1)))
+}
@@ -0,0 +1,76 @@
+package maybego
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// twenty emulated seconds, mooneye ROMs finish in a fraction of that
+const mooneyeTimeout uint = 20 * 1048576
+
+const (
+	mooneyeRunning = iota
+	mooneyePassed
+	mooneyeFailed
+)
+
+// Mooneye ROMs execute LD B,B with the fibonacci numbers in B-L on success
+// and 0x42 in every register on failure.
+func mooneyeResult(reg *Registers) int {
+	if reg.B == 3 && reg.C == 5 && reg.D == 8 && reg.E == 13 && reg.H == 21 && reg.L == 34 {
+		return mooneyePassed
+	}
+	if reg.B == 0x42 && reg.C == 0x42 && reg.D == 0x42 && reg.E == 0x42 && reg.H == 0x42 && reg.L == 0x42 {
+		return mooneyeFailed
+	}
+	return mooneyeRunning
+}
+
+// Runs every ROM below testdata/mooneye and prints a pass/fail matrix.
+func TestMooneye(t *testing.T) {
+	dir := filepath.Join("testdata", "mooneye")
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip("testdata/mooneye does not exist")
+	}
+
+	var roms []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".gb" {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+
+	var matrix strings.Builder
+	for _, rom := range roms {
+		name, _ := filepath.Rel(dir, rom)
+		result := "timeout"
+		t.Run(name, func(t *testing.T) {
+			emu := loadTestRom(t, rom)
+			state := mooneyeRunning
+			emu.GetCPU().SetDebugBreakpoint(func() {
+				state = mooneyeResult(emu.cpu.reg)
+			})
+
+			for state == mooneyeRunning && emu.GetCPUState().cycles < mooneyeTimeout {
+				emu.RunFrame()
+			}
+
+			switch state {
+			case mooneyePassed:
+				result = "pass"
+			case mooneyeFailed:
+				result = "FAIL"
+				t.Fail()
+			default:
+				t.Errorf("Timed out after %d cycles", mooneyeTimeout)
+			}
+		})
+		fmt.Fprintf(&matrix, "%-7s %s\n", result, name)
+	}
+	t.Logf("\n%s", matrix.String())
+}
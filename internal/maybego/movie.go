@@ -0,0 +1,182 @@
+package maybego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const movieMagic = "MGMV"
+const movieVersion uint16 = 1
+
+var ErrNotMovie = errors.New("not a MaybeGo movie")
+var ErrMovieRom = errors.New("movie was recorded with a different ROM")
+
+// A day of frames. Headers with longer movies, or states larger than a save state,
+// come from corrupt files and are rejected before anything is allocated for them.
+const maxMovieFrames = 60 * 60 * 60 * 24
+
+var maxMovieStateLength = binary.Size(saveStateHeader{}) + binary.Size(saveState{})
+
+type movieHeader struct {
+	Magic       [4]byte
+	Version     uint16
+	RomChecksum uint32
+	StateLength uint32 // 0 if the movie starts at power-on
+	Frames      uint32
+}
+
+// Joypad input for every frame, recorded from or played back into an emulator.
+// While a movie is active, input only reaches the joypad at frame boundaries,
+// so playback sees exactly the same input on exactly the same frame.
+type Movie struct {
+	rom_checksum uint32
+	state        []byte // save state to start from, nil for power-on
+	inputs       []byte // directions in the high nibble, buttons in the low one
+	frame        int
+	recording    bool
+	// what PressButton/ReleaseButton change while the movie is active
+	input Joypad
+}
+
+func (movie *Movie) Len() int {
+	return len(movie.inputs)
+}
+
+// Starts recording a movie. If from_state is false, the emulator is powered on first.
+func (emu *Emulator) StartRecording(from_state bool) error {
+	movie := &Movie{rom_checksum: emu.rom_checksum, recording: true}
+	if from_state {
+		var state bytes.Buffer
+		if err := emu.SaveState(&state); err != nil {
+			return err
+		}
+		movie.state = state.Bytes()
+	} else {
+		emu.PowerOn()
+	}
+
+	movie.input = *emu.joypad
+	emu.movie = movie
+	movie.nextFrame(emu.joypad)
+	return nil
+}
+
+// Ends recording or playback and returns the movie.
+func (emu *Emulator) StopMovie() *Movie {
+	movie := emu.movie
+	if movie == nil {
+		return nil
+	}
+	if movie.recording {
+		// the input of the last entry was never used for a frame
+		movie.inputs = movie.inputs[:len(movie.inputs)-1]
+		movie.recording = false
+	}
+
+	emu.movie = nil
+	return movie
+}
+
+// Restores the starting state of the movie and plays back its input.
+// Playback stops by itself after the last frame.
+func (emu *Emulator) PlayMovie(movie *Movie) error {
+	if movie.rom_checksum != emu.rom_checksum {
+		return ErrMovieRom
+	}
+	if movie.state != nil {
+		if err := emu.LoadState(bytes.NewReader(movie.state)); err != nil {
+			return err
+		}
+	} else {
+		emu.PowerOn()
+	}
+
+	movie.frame = 0
+	movie.recording = false
+	movie.input = *emu.joypad
+	emu.movie = movie
+	movie.nextFrame(emu.joypad)
+	return nil
+}
+
+func (emu *Emulator) MovieActive() bool {
+	return emu.movie != nil
+}
+
+// Called before every frame.
+func (movie *Movie) nextFrame(joy *Joypad) {
+	if movie.recording {
+		movie.inputs = append(movie.inputs, movie.input.directions<<4|movie.input.buttons&0xF)
+		joy.directions = movie.input.directions
+		joy.buttons = movie.input.buttons
+		joy.update()
+		return
+	}
+
+	if movie.frame >= len(movie.inputs) {
+		return
+	}
+	joy.directions = movie.inputs[movie.frame] >> 4
+	joy.buttons = movie.inputs[movie.frame] & 0xF
+	joy.update()
+	movie.frame++
+}
+
+// Playback is done once the input of the last frame was used and that frame finished.
+func (movie *Movie) finished() bool {
+	return !movie.recording && movie.frame >= len(movie.inputs)
+}
+
+func (movie *Movie) Write(w io.Writer) error {
+	header := movieHeader{
+		Version:     movieVersion,
+		RomChecksum: movie.rom_checksum,
+		StateLength: uint32(len(movie.state)),
+		Frames:      uint32(len(movie.inputs)),
+	}
+	copy(header.Magic[:], movieMagic)
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if _, err := w.Write(movie.state); err != nil {
+		return err
+	}
+	_, err := w.Write(movie.inputs)
+	return err
+}
+
+func ReadMovie(r io.Reader) (*Movie, error) {
+	var header movieHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != movieMagic {
+		return nil, ErrNotMovie
+	}
+	if header.Version != movieVersion {
+		return nil, fmt.Errorf("unsupported movie version %d, expected %d", header.Version, movieVersion)
+	}
+
+	if header.Frames > maxMovieFrames {
+		return nil, fmt.Errorf("movie has %d frames, at most %d are supported", header.Frames, maxMovieFrames)
+	}
+	if int64(header.StateLength) > int64(maxMovieStateLength) {
+		return nil, fmt.Errorf("movie state has %d bytes, a save state at most %d", header.StateLength, maxMovieStateLength)
+	}
+
+	movie := &Movie{rom_checksum: header.RomChecksum, inputs: make([]byte, header.Frames)}
+	if header.StateLength > 0 {
+		movie.state = make([]byte, header.StateLength)
+		if _, err := io.ReadFull(r, movie.state); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.ReadFull(r, movie.inputs); err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
@@ -0,0 +1,112 @@
+package maybego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Selects the buttons and adds the JOYP value to 0xC000 in a loop,
+// so the result depends on exactly when a button was pressed.
+func joypadSumRom() []byte {
+	return programRom(
+		0x3E, 0x10, // LD A, 0x10
+		0xE0, 0x00, // LDH (0x00), A
+		0xF0, 0x00, // LDH A, (0x00)
+		0x21, 0x00, 0xC0, // LD HL, 0xC000
+		0x86,       // ADD A, (HL)
+		0x77,       // LD (HL), A
+		0x18, 0xF3, // JR 0x100
+	)
+}
+
+func runRecordedInput(emu *Emulator) {
+	for frame := range 30 {
+		switch frame {
+		case 3:
+			emu.PressButton(ButtonA)
+		case 10:
+			emu.PressButton(ButtonStart)
+		case 11:
+			emu.ReleaseButton(ButtonA)
+		case 20:
+			emu.ReleaseButton(ButtonStart)
+		}
+		emu.RunFrame()
+	}
+}
+
+func TestMoviePlaybackIsDeterministic(t *testing.T) {
+	emu := loadProgram(joypadSumRom(), ModelAuto)
+
+	for _, from_state := range []bool{false, true} {
+		// run a bit first, so starting from a state differs from power-on
+		emu.RunFrame()
+		if err := emu.StartRecording(from_state); err != nil {
+			t.Fatal(err)
+		}
+		runRecordedInput(emu)
+		movie := emu.StopMovie()
+
+		var recorded bytes.Buffer
+		emu.SaveState(&recorded)
+
+		var file bytes.Buffer
+		if err := movie.Write(&file); err != nil {
+			t.Fatal(err)
+		}
+		movie, err := ReadMovie(&file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if movie.Len() != 30 {
+			t.Errorf("Got %d frames, expected 30", movie.Len())
+		}
+
+		emu.PressButton(ButtonB) // must be ignored during playback
+		if err := emu.PlayMovie(movie); err != nil {
+			t.Fatal(err)
+		}
+		for emu.MovieActive() {
+			emu.RunFrame()
+		}
+		emu.ReleaseButton(ButtonB)
+
+		var played bytes.Buffer
+		emu.SaveState(&played)
+		if !bytes.Equal(recorded.Bytes(), played.Bytes()) {
+			t.Errorf("Playback (from state: %t) differs from the recording", from_state)
+		}
+	}
+}
+
+func TestPlayMovieRejectsOtherRoms(t *testing.T) {
+	emu := loadProgram(joypadSumRom(), ModelAuto)
+	emu.StartRecording(false)
+	movie := emu.StopMovie()
+
+	other := joypadSumRom()
+	other[0x150] = 0xFF
+	emu.LoadRom(&other)
+	if err := emu.PlayMovie(movie); err != ErrMovieRom {
+		t.Errorf("Got error %v, expected %v", err, ErrMovieRom)
+	}
+}
+
+func TestReadMovieRejectsCorruptHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header movieHeader
+	}{
+		{"frames", movieHeader{Version: movieVersion, Frames: 0xFFFFFFFF}},
+		{"state", movieHeader{Version: movieVersion, StateLength: 0xFFFFFFFF}},
+	}
+	for _, test := range tests {
+		copy(test.header.Magic[:], movieMagic)
+		var file bytes.Buffer
+		binary.Write(&file, binary.LittleEndian, &test.header)
+		if _, err := ReadMovie(&file); err == nil {
+			t.Errorf("Movie with too large %s accepted, expected an error", test.name)
+		}
+	}
+}
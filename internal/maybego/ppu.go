@@ -1,5 +1,10 @@
 package maybego
 
+import (
+	"image"
+	"image/color"
+)
+
 const (
 	LCDC      uint16 = 0xFF40
 	STAT      uint16 = 0xFF41
@@ -19,7 +24,16 @@ type PPU struct {
 	logger   *Logger
 }
 
-var framebufferPalette [160 * 144]byte
+var Palette = []color.RGBA{
+	{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+	{R: 0x9F, G: 0x9F, B: 0x9F, A: 0xFF},
+	{R: 0x60, G: 0x60, B: 0x60, A: 0xFF},
+	{R: 0x00, G: 0x00, B: 0x00, A: 0xFF},
+}
+
+// RGB555, like the CGB palette RAM
+var framebuffer [160 * 144]uint16
+
 var BGMapPalette [256 * 256]byte
 var paletteValues [4]byte
 
@@ -33,8 +47,36 @@ func NewPPU(logger *Logger) *PPU {
 	return ppu
 }
 
-func (ppu *PPU) GetCurrentFrame() *[160 * 144]byte {
-	return &framebufferPalette
+// Returns the current frame in RGB555.
+func (ppu *PPU) GetCurrentFrame() *[160 * 144]uint16 {
+	return &framebuffer
+}
+
+func (ppu *PPU) GetFrameImage() *image.RGBA {
+	frame := ppu.GetCurrentFrame()
+	img := image.NewRGBA(image.Rect(0, 0, 160, 144))
+	for y := range 144 {
+		for x := range 160 {
+			img.SetRGBA(x, y, rgba(frame[(160*y)+x]))
+		}
+	}
+	return img
+}
+
+// Returns the color of a BG pixel: palette RAM in CGB mode, BGP through
+// the palette of the boot rom in compatibility mode, BGP through the SGB palette
+// of the tile, or the display palette on a DMG.
+func bgColor(attributes byte, pixelcolor byte) uint16 {
+	switch {
+	case sgb.enabled:
+		return sgb.palettes[attributes&0x3][paletteValues[pixelcolor]]
+	case cgb.enabled:
+		return paletteColor(&cgb.bg_palettes, attributes&0x7, pixelcolor)
+	case cgb.compat:
+		return paletteColor(&cgb.bg_palettes, 0, paletteValues[pixelcolor])
+	default:
+		return rgb555(Palette[paletteValues[pixelcolor]])
+	}
 }
 
 func (ppu *PPU) RenderBG(row byte) {
@@ -69,7 +111,26 @@ func (ppu *PPU) RenderBG(row byte) {
 	for j := 0; j < /*(SCX + */ 256; j += 1 {
 		x := j // + SCX
 		tileX := uint16(x / 8)
-		tileID := Read(ppu.tilemap + uint16((y/8)*32) + tileX)
+		map_address := ppu.tilemap + uint16((y/8)*32) + tileX
+		tileID := vramRead(0, map_address)
+		// palette, tile bank, x flip, y flip and priority, in bank 1 next to the tile ids
+		var attributes byte
+		if cgb.enabled {
+			attributes = vramRead(1, map_address)
+		}
+		// the SGB palette of the tile on screen
+		if sgb.enabled && x < 160 {
+			attributes = sgbAttribute(x, int(ppu.scanline))
+		}
+		tile_row := y % 8
+		if attributes&0x40 != 0 {
+			tile_row = 7 - tile_row
+		}
+		tile_bit := x % 8
+		if attributes&0x20 != 0 {
+			tile_bit = 7 - tile_bit
+		}
+		bank := (attributes >> 3) & 0x1
 
 		var tileY uint16
 
@@ -81,10 +142,10 @@ func (ppu *PPU) RenderBG(row byte) {
 		} else {
 			tileY = uint16(tileID) * uint16(0x10)
 		}
-		address := ppu.tiledata + tileY + uint16((y%8)*2)
+		address := ppu.tiledata + tileY + uint16(tile_row*2)
 
-		pixelcolor := (Read(address) >> (7 - (x % 8)) & 0x1) +
-			(Read(address+1)>>(7-(x%8))&0x1)*2
+		pixelcolor := (vramRead(bank, address) >> (7 - tile_bit) & 0x1) +
+			(vramRead(bank, address+1)>>(7-tile_bit)&0x1)*2
 		// pixelcolor := address
 		// fmt.Printf("")
 		// pixelcolor := (Read(address) >> (7 - (x % 8)) & 0x1) +
@@ -99,7 +160,8 @@ func (ppu *PPU) RenderBG(row byte) {
 		// }
 		BGMapPalette[y*256+x] = paletteValues[pixelcolor]
 		if x /* - SCX */ < 160 && y < 144 {
-			framebufferPalette[(int(ppu.scanline)*160)+x] = paletteValues[pixelcolor]
+			pixel := (int(ppu.scanline) * 160) + x
+			framebuffer[pixel] = bgColor(attributes, pixelcolor)
 		}
 	}
 }
@@ -113,6 +175,9 @@ func (ppu *PPU) Render(cycles byte) bool {
 	// }
 
 	new_dots := uint16(cycles * 4)
+	if doubleSpeed() {
+		new_dots /= 2
+	}
 	row_done := (ppu.dots + new_dots) > 455
 	ppu.dots = (ppu.dots + new_dots) % 456
 	// ppu.logger.LogValue("dots", ppu.dots)
@@ -143,6 +208,9 @@ func (ppu *PPU) Render(cycles byte) bool {
 			if cur_stat&0x8 != 0 {
 				RequestInterrupt(1)
 			}
+			if Read(LY) < 144 {
+				hblankVramDMA()
+			}
 		}
 	}
 	if !row_done {
@@ -208,6 +276,8 @@ func (ppu *PPU) Reset() {
 	ppu.tiledata = 0
 	ppu.tilemap = 0
 
-	framebufferPalette = [160 * 144]byte{}
+	for i := range framebuffer {
+		framebuffer[i] = rgb555(Palette[0])
+	}
 	BGMapPalette = [256 * 256]byte{}
 }
@@ -4,9 +4,7 @@ import (
 	"testing"
 )
 
-var logger *Logger = NewLogger(false, "")
 var ppu *PPU = NewPPU(logger)
-var cpu *CPU = NewCPU(logger)
 
 func TestRowTransition(t *testing.T) {
 	var tests = []struct {
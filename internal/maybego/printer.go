@@ -0,0 +1,272 @@
+package maybego
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Printer commands
+const (
+	PRINTER_INIT   byte = 0x01
+	PRINTER_PRINT  byte = 0x02
+	PRINTER_DATA   byte = 0x04
+	PRINTER_STATUS byte = 0x0F
+)
+
+// Printer status bits
+const (
+	PRINTER_CHECKSUM_ERROR byte = 0x01
+	PRINTER_PRINTING       byte = 0x02
+	PRINTER_FULL           byte = 0x04
+	PRINTER_UNPROCESSED    byte = 0x08
+)
+
+// Answered instead of the status when the printer is connected.
+const printerAlive byte = 0x81
+
+// 20x18 tiles, one screen
+const printerBufferSize = 20 * 18 * 16
+
+// Paper shades of the four print colors
+var printerShades = [4]color.Gray{{0xFF}, {0xAA}, {0x55}, {0x00}}
+
+// Position within a packet:
+// 0x88 0x33 command compression length(2) data... checksum(2) alive status
+const (
+	printerMagic1 = iota
+	printerMagic2
+	printerCommand
+	printerCompression
+	printerLengthLow
+	printerLengthHigh
+	printerData
+	printerChecksumLow
+	printerChecksumHigh
+	printerAliveByte
+	printerStatusByte
+)
+
+// The Game Boy Printer, attached to the serial port like a link cable.
+// Every print job is written as print-NNN.png into its directory.
+type Printer struct {
+	dir      string
+	position int
+	command  byte
+	compress bool
+	length   uint16
+	data     []byte
+	checksum uint16
+	received uint16
+	status   byte
+	image    []byte // tile data, 20 tiles per row
+	prints   []string
+	err      error
+}
+
+func NewPrinter(dir string) *Printer {
+	return &Printer{dir: dir}
+}
+
+// Returns the files written so far.
+func (printer *Printer) Prints() []string {
+	return printer.prints
+}
+
+// The Game Boy always clocks the printer, which answers at once.
+func (printer *Printer) exchange(out byte) (byte, bool) {
+	return printer.shift(out), true
+}
+
+// Takes the next byte of a packet, returns the byte the printer shifts out.
+func (printer *Printer) shift(out byte) byte {
+	reply := byte(0x00)
+
+	switch printer.position {
+	case printerMagic1:
+		if out == 0x88 {
+			printer.position++
+		}
+		return reply
+	case printerMagic2:
+		if out == 0x33 {
+			printer.position++
+		} else {
+			printer.position = printerMagic1
+		}
+		return reply
+	case printerCommand:
+		printer.command = out
+		printer.checksum = uint16(out)
+	case printerCompression:
+		printer.compress = out&0x1 != 0
+		printer.checksum += uint16(out)
+	case printerLengthLow:
+		printer.length = uint16(out)
+		printer.checksum += uint16(out)
+	case printerLengthHigh:
+		printer.length |= uint16(out) << 8
+		printer.checksum += uint16(out)
+		printer.data = printer.data[:0]
+		if printer.length == 0 {
+			printer.position = printerChecksumLow
+			return reply
+		}
+	case printerData:
+		printer.data = append(printer.data, out)
+		printer.checksum += uint16(out)
+		if len(printer.data) < int(printer.length) {
+			return reply
+		}
+	case printerChecksumLow:
+		printer.received = uint16(out)
+	case printerChecksumHigh:
+		printer.received |= uint16(out) << 8
+		printer.handlePacket()
+	case printerAliveByte:
+		reply = printerAlive
+	case printerStatusByte:
+		reply = printer.status
+		if printer.command == PRINTER_STATUS {
+			// the print is done once the game saw it printing
+			printer.status &^= PRINTER_PRINTING
+		}
+		printer.position = printerMagic1
+		return reply
+	}
+
+	printer.position++
+	return reply
+}
+
+func (printer *Printer) poll(serial *Serial) {}
+
+// Returns the first error of writing a print.
+func (printer *Printer) Close() error {
+	return printer.err
+}
+
+func (printer *Printer) handlePacket() {
+	if printer.received != printer.checksum {
+		printer.status |= PRINTER_CHECKSUM_ERROR
+		return
+	}
+	printer.status &^= PRINTER_CHECKSUM_ERROR
+
+	switch printer.command {
+	case PRINTER_INIT:
+		printer.image = printer.image[:0]
+		printer.status = 0
+	case PRINTER_DATA:
+		// a packet without data marks the end of the image
+		if printer.length == 0 {
+			return
+		}
+		data := printer.data
+		if printer.compress {
+			data = decompressPrinterData(data)
+		}
+		printer.image = append(printer.image, data...)
+		if len(printer.image) > printerBufferSize {
+			printer.image = printer.image[:printerBufferSize]
+		}
+		printer.status |= PRINTER_UNPROCESSED
+		if len(printer.image) == printerBufferSize {
+			printer.status |= PRINTER_FULL
+		}
+	case PRINTER_PRINT:
+		if len(printer.data) < 4 {
+			return
+		}
+		sheets, palette := printer.data[0], printer.data[2]
+		if sheets > 0 && len(printer.image) > 0 {
+			if err := printer.writeImage(palette); err != nil && printer.err == nil {
+				printer.err = err
+			}
+		}
+		printer.image = printer.image[:0]
+		printer.status = printer.status&^(PRINTER_UNPROCESSED|PRINTER_FULL) | PRINTER_PRINTING
+	}
+}
+
+// A control byte with bit 7 set repeats the next byte (n&0x7F)+2 times,
+// otherwise the next n+1 bytes are copied.
+func decompressPrinterData(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+		if control&0x80 != 0 {
+			if i >= len(data) {
+				break
+			}
+			for range int(control&0x7F) + 2 {
+				out = append(out, data[i])
+			}
+			i++
+		} else {
+			end := min(i+int(control)+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		}
+	}
+	return out
+}
+
+// Converts the buffered tiles to an image. Each 2 bit color is mapped
+// through the palette of the print command, 0 means the default 0xE4.
+func (printer *Printer) renderImage(palette byte) *image.Gray {
+	if palette == 0 {
+		palette = 0xE4
+	}
+	tiles := len(printer.image) / 16
+	rows := (tiles + 19) / 20
+	img := image.NewGray(image.Rect(0, 0, 160, rows*8))
+
+	for tile := range tiles {
+		tileX, tileY := (tile%20)*8, (tile/20)*8
+		for y := range 8 {
+			low := printer.image[tile*16+y*2]
+			high := printer.image[tile*16+y*2+1]
+			for x := range 8 {
+				pixel := (low>>(7-x))&0x1 | ((high>>(7-x))&0x1)<<1
+				shade := (palette >> (pixel * 2)) & 0x3
+				img.SetGray(tileX+x, tileY+y, printerShades[shade])
+			}
+		}
+	}
+	return img
+}
+
+func (printer *Printer) writeImage(palette byte) error {
+	if err := os.MkdirAll(printer.dir, 0777); err != nil {
+		return err
+	}
+
+	// never overwrite prints of an earlier session
+	var path string
+	var file *os.File
+	for n := len(printer.prints) + 1; ; n++ {
+		path = filepath.Join(printer.dir, fmt.Sprintf("print-%03d.png", n))
+		var err error
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, printer.renderImage(palette)); err != nil {
+		return err
+	}
+	printer.prints = append(printer.prints, path)
+	return nil
+}
@@ -0,0 +1,145 @@
+package maybego
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func printerPacket(command byte, compression byte, data []byte) []byte {
+	packet := []byte{0x88, 0x33, command, compression, byte(len(data)), byte(len(data) >> 8)}
+	packet = append(packet, data...)
+	checksum := uint16(0)
+	for _, b := range packet[2:] {
+		checksum += uint16(b)
+	}
+	return append(packet, byte(checksum), byte(checksum>>8), 0x00, 0x00)
+}
+
+// Returns the alive byte and status the printer answered with.
+func sendPacket(printer *Printer, packet []byte) (byte, byte) {
+	var replies []byte
+	for _, b := range packet {
+		replies = append(replies, printer.shift(b))
+	}
+	return replies[len(replies)-2], replies[len(replies)-1]
+}
+
+func TestDecompressPrinterData(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected []byte
+	}{
+		{[]byte{0x02, 0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03}},
+		{[]byte{0x81, 0xAA}, []byte{0xAA, 0xAA, 0xAA}},
+		{[]byte{0x00, 0x11, 0x80, 0x22, 0x01, 0x33, 0x44}, []byte{0x11, 0x22, 0x22, 0x33, 0x44}},
+	}
+
+	for _, test := range tests {
+		actual := decompressPrinterData(test.data)
+		if !bytes.Equal(actual, test.expected) {
+			t.Errorf("Decompressed %x: %x; expected: %x", test.data, actual, test.expected)
+		}
+	}
+}
+
+func TestPrinterStatus(t *testing.T) {
+	printer := NewPrinter(t.TempDir())
+
+	alive, status := sendPacket(printer, printerPacket(PRINTER_INIT, 0, nil))
+	if alive != printerAlive || status != 0 {
+		t.Errorf("Got alive: %x status: %x; expected: %x %x", alive, status, printerAlive, 0)
+	}
+
+	packet := printerPacket(PRINTER_STATUS, 0, nil)
+	packet[6]++ // checksum
+	if _, status := sendPacket(printer, packet); status != PRINTER_CHECKSUM_ERROR {
+		t.Errorf("Current status: %x; expected: %x", status, PRINTER_CHECKSUM_ERROR)
+	}
+
+	sendPacket(printer, printerPacket(PRINTER_DATA, 0, make([]byte, 32)))
+	if _, status := sendPacket(printer, printerPacket(PRINTER_STATUS, 0, nil)); status != PRINTER_UNPROCESSED {
+		t.Errorf("Current status: %x; expected: %x", status, PRINTER_UNPROCESSED)
+	}
+}
+
+func TestPrinterPrint(t *testing.T) {
+	printer := NewPrinter(t.TempDir())
+
+	// one row of 20 tiles, each with a line of every color at the top
+	tile := make([]byte, 16)
+	tile[0], tile[1] = 0x55, 0x33
+	var data []byte
+	for range 20 {
+		data = append(data, tile...)
+	}
+
+	sendPacket(printer, printerPacket(PRINTER_INIT, 0, nil))
+	// all but the first tile RLE-compressed, a literal run followed by 14 zeros each
+	sendPacket(printer, printerPacket(PRINTER_DATA, 0, data[:16]))
+	var compressed []byte
+	for range 19 {
+		compressed = append(compressed, 0x01, 0x55, 0x33, 0x8C, 0x00)
+	}
+	sendPacket(printer, printerPacket(PRINTER_DATA, 1, compressed))
+	sendPacket(printer, printerPacket(PRINTER_DATA, 0, nil))
+	_, status := sendPacket(printer, printerPacket(PRINTER_PRINT, 0, []byte{1, 0x13, 0xE4, 0x40}))
+	if status&PRINTER_PRINTING == 0 {
+		t.Errorf("Current status: %x; expected printing", status)
+	}
+	sendPacket(printer, printerPacket(PRINTER_STATUS, 0, nil))
+	if _, status := sendPacket(printer, printerPacket(PRINTER_STATUS, 0, nil)); status != 0 {
+		t.Errorf("Current status: %x; expected: %x", status, 0)
+	}
+
+	if len(printer.Prints()) != 1 {
+		t.Fatalf("Got %d prints, expected 1", len(printer.Prints()))
+	}
+	file, err := os.Open(printer.Prints()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != 160 || img.Bounds().Dy() != 8 {
+		t.Errorf("Got a %v image, expected 160x8", img.Bounds().Size())
+	}
+	// 0x55 and 0x33 give the colors 0, 1, 2, 3 from the left, twice
+	expected := []uint32{0xFFFF, 0xAAAA, 0x5555, 0x0000}
+	for x := range 8 {
+		r, _, _, _ := img.At(152+x, 0).RGBA()
+		if r != expected[x%4] {
+			t.Errorf("Pixel %d: %x; expected: %x", x, r, expected[x%4])
+		}
+	}
+	if r, _, _, _ := img.At(0, 1).RGBA(); r != 0xFFFF {
+		t.Errorf("Pixel (0, 1): %x; expected: %x", r, 0xFFFF)
+	}
+}
+
+func TestPrinterKeepsEarlierPrints(t *testing.T) {
+	dir := t.TempDir()
+	earlier := filepath.Join(dir, "print-001.png")
+	os.WriteFile(earlier, []byte("earlier"), 0666)
+	// a name that cannot be written is skipped like a file
+	os.Mkdir(filepath.Join(dir, "print-002.png"), 0777)
+
+	printer := NewPrinter(dir)
+	printer.image = make([]byte, 20*16)
+	if err := printer.writeImage(0); err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "print-003.png"); !slices.Equal(printer.Prints(), []string{expected}) {
+		t.Errorf("Current prints: %q; expected: %q", printer.Prints(), expected)
+	}
+	if data, _ := os.ReadFile(earlier); string(data) != "earlier" {
+		t.Errorf("Current earlier print: %q; expected it unchanged", data)
+	}
+}
@@ -0,0 +1,149 @@
+package maybego
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+var ErrRewindEmpty = errors.New("no earlier snapshot to rewind to")
+
+const DefaultRewindBudget = 32 * 1024 * 1024
+
+// Keeps periodic save states to step back in time.
+// Only the newest snapshot is stored in full. For every older one, the
+// compressed XOR against its successor is kept, so stepping back is a
+// single decompression and the oldest entries can be dropped for free.
+type Rewind struct {
+	budget   int // bytes for all deltas
+	interval int // frames between snapshots
+	frames   int
+	current  []byte
+	deltas   [][]byte
+	size     int
+}
+
+// Creates a rewind buffer that keeps at most budget bytes of history and
+// takes a snapshot every interval frames.
+func NewRewind(budget int, interval int) *Rewind {
+	return &Rewind{budget: budget, interval: max(interval, 1)}
+}
+
+// Returns the number of snapshots that can be stepped back.
+func (rw *Rewind) Len() int {
+	return len(rw.deltas)
+}
+
+// Should be called after every frame.
+// A budget of 0 disables snapshots entirely.
+func (rw *Rewind) Capture(emu *Emulator) error {
+	if rw.budget == 0 {
+		return nil
+	}
+	rw.frames++
+	if rw.frames < rw.interval {
+		return nil
+	}
+	rw.frames = 0
+
+	var snapshot bytes.Buffer
+	if err := emu.SaveState(&snapshot); err != nil {
+		return err
+	}
+
+	if rw.current != nil {
+		delta, err := compressDelta(snapshot.Bytes(), rw.current)
+		if err != nil {
+			return err
+		}
+		rw.deltas = append(rw.deltas, delta)
+		rw.size += len(delta)
+	}
+	rw.current = snapshot.Bytes()
+
+	for rw.size > rw.budget && len(rw.deltas) > 0 {
+		rw.size -= len(rw.deltas[0])
+		rw.deltas[0] = nil
+		rw.deltas = rw.deltas[1:]
+	}
+	return nil
+}
+
+// Restores the newest snapshot if the emulator moved on since it was taken
+// (e.g. it stopped at a breakpoint mid-frame), otherwise the one before it.
+func (rw *Rewind) Step(emu *Emulator) error {
+	if rw.current == nil {
+		return ErrRewindEmpty
+	}
+
+	var now bytes.Buffer
+	if err := emu.SaveState(&now); err != nil {
+		return err
+	}
+	if !bytes.Equal(now.Bytes(), rw.current) {
+		rw.frames = 0
+		return emu.LoadState(bytes.NewReader(rw.current))
+	}
+
+	if len(rw.deltas) == 0 {
+		return ErrRewindEmpty
+	}
+
+	last := len(rw.deltas) - 1
+	previous, err := applyDelta(rw.current, rw.deltas[last])
+	if err != nil {
+		return err
+	}
+	if err := emu.LoadState(bytes.NewReader(previous)); err != nil {
+		return err
+	}
+
+	rw.size -= len(rw.deltas[last])
+	rw.deltas = rw.deltas[:last]
+	rw.current = previous
+	rw.frames = 0
+	return nil
+}
+
+func (rw *Rewind) Reset() {
+	rw.frames = 0
+	rw.current = nil
+	rw.deltas = nil
+	rw.size = 0
+}
+
+// Both snapshots have the same size, since save states only contain fixed-size fields.
+func compressDelta(newer []byte, older []byte) ([]byte, error) {
+	xored := make([]byte, len(newer))
+	for i := range xored {
+		xored[i] = newer[i] ^ older[i]
+	}
+
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(xored); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func applyDelta(newer []byte, delta []byte) ([]byte, error) {
+	older := make([]byte, len(newer))
+	reader := flate.NewReader(bytes.NewReader(delta))
+	defer reader.Close()
+	if _, err := io.ReadFull(reader, older); err != nil {
+		return nil, err
+	}
+
+	for i := range older {
+		older[i] ^= newer[i]
+	}
+	return older, nil
+}
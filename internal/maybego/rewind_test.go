@@ -0,0 +1,93 @@
+package maybego
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRewindStepsBack(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	rewind := NewRewind(1<<20, 1)
+
+	for i := range 10 {
+		Write(0xC000, byte(i))
+		emu.cpu.reg.A = byte(i)
+		if err := rewind.Capture(emu); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rewind.Len() != 9 {
+		t.Errorf("Got %d snapshots to step back, expected 9", rewind.Len())
+	}
+
+	for i := 8; i >= 0; i-- {
+		if err := rewind.Step(emu); err != nil {
+			t.Fatal(err)
+		}
+		if Read(0xC000) != byte(i) || emu.cpu.reg.A != byte(i) {
+			t.Errorf("Got Memory[0xC000]: %d A: %d, expected %d", Read(0xC000), emu.cpu.reg.A, i)
+		}
+	}
+
+	if err := rewind.Step(emu); err != ErrRewindEmpty {
+		t.Errorf("Got error %v, expected %v", err, ErrRewindEmpty)
+	}
+}
+
+func TestRewindReturnsToNewestSnapshotFirst(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	rewind := NewRewind(1<<20, 1)
+
+	Write(0xC000, 1)
+	rewind.Capture(emu)
+	Write(0xC000, 2)
+	rewind.Capture(emu)
+	Write(0xC000, 3) // e.g. halted at a breakpoint before the next frame
+
+	rewind.Step(emu)
+	if Read(0xC000) != 2 {
+		t.Errorf("Got Memory[0xC000]: %d, expected 2", Read(0xC000))
+	}
+	rewind.Step(emu)
+	if Read(0xC000) != 1 {
+		t.Errorf("Got Memory[0xC000]: %d, expected 1", Read(0xC000))
+	}
+}
+
+func TestRewindBudget(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	rewind := NewRewind(64*1024, 1)
+	random := rand.New(rand.NewSource(1))
+
+	for range 100 {
+		// change a lot of memory, so the deltas do not compress to nothing
+		for adr := 0xC000; adr < 0xC800; adr++ {
+			Write(uint16(adr), byte(random.Intn(256)))
+		}
+		rewind.Capture(emu)
+	}
+
+	if rewind.size > rewind.budget {
+		t.Errorf("Rewind uses %d bytes, budget is %d", rewind.size, rewind.budget)
+	}
+	if rewind.Len() == 0 || rewind.Len() == 99 {
+		t.Errorf("Got %d snapshots, expected the oldest ones to be dropped", rewind.Len())
+	}
+}
+
+func TestRewindInterval(t *testing.T) {
+	emu := NewEmulator(logger)
+	rewind := NewRewind(1<<20, 4)
+
+	for range 12 {
+		rewind.Capture(emu)
+	}
+
+	if rewind.Len() != 2 {
+		t.Errorf("Got %d snapshots to step back, expected 2", rewind.Len())
+	}
+}
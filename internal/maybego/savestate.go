@@ -0,0 +1,241 @@
+package maybego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Bump saveStateVersion whenever saveState changes and add a migration to LoadState
+// if older states can still be converted.
+const saveStateMagic = "MGSS"
+const saveStateVersion uint16 = 6
+
+var ErrNotSaveState = errors.New("not a MaybeGo save state")
+
+type saveStateHeader struct {
+	Magic   [4]byte
+	Version uint16
+}
+
+// Everything needed to continue emulation, with fixed-size fields only
+// so it can be written with encoding/binary.
+// There is no mapper or APU yet; they get added here once they exist.
+type saveState struct {
+	Registers  Registers
+	Flags      Flags
+	PendingIME bool
+	// the shadow call stack of the debugger, the innermost call at CallDepth-1
+	Calls     [maxCallDepth]CallFrame
+	CallDepth uint16
+	Clocks    struct {
+		DivClocksum   byte
+		TimerClocksum uint64
+		Cycles        uint64
+	}
+	Memory [65536]byte
+	PPU    struct {
+		Tilemap     uint16
+		Tiledata    uint16
+		Dots        uint16
+		Scanline    byte
+		Framebuffer [160 * 144]uint16
+	}
+	Joypad struct {
+		PrevJoypad byte
+		Directions byte
+		Buttons    byte
+	}
+	Serial struct {
+		Transferring bool
+		Clocksum     uint64
+	}
+	CGB struct {
+		Enabled     bool
+		Compat      bool
+		VRAM1       [0x2000]byte
+		WRAM        [8][0x1000]byte
+		BGPalettes  [64]byte
+		OBJPalettes [64]byte
+		HDMA        struct {
+			Source      uint16
+			Destination uint16
+			Blocks      uint16
+			Active      bool
+			Stall       uint64
+		}
+	}
+	SGB struct {
+		Enabled         bool
+		SelectBits      byte
+		Receiving       bool
+		Bits            uint16
+		Packet          [16]byte
+		Data            [7 * 16]byte
+		Packets         byte
+		Palettes        [4][4]uint16
+		SystemPalettes  [512][4]uint16
+		Attributes      [20 * 18]byte
+		AttributeFiles  [45][90]byte
+		Mask            byte
+		Frozen          [160 * 144]uint16
+		TransferPending bool
+		Transfer        byte
+		TransferArg     byte
+		BorderTiles     [256 * 32]byte
+		BorderMap       [32 * 32]uint16
+		BorderPalettes  [4][16]uint16
+		Players         byte
+		Player          byte
+	}
+}
+
+func (emu *Emulator) SaveState(w io.Writer) error {
+	header := saveStateHeader{Version: saveStateVersion}
+	copy(header.Magic[:], saveStateMagic)
+
+	state := new(saveState)
+	state.Registers = *emu.cpu.reg
+	state.Flags = *emu.cpu.flg
+	state.PendingIME = emu.cpu.pendingIME
+	state.CallDepth = uint16(copy(state.Calls[:], emu.cpu.calls))
+	state.Clocks.DivClocksum = emu.cpu.clk.div_clocksum
+	state.Clocks.TimerClocksum = uint64(emu.cpu.clk.timer_clocksum)
+	state.Clocks.Cycles = uint64(emu.cpu.clk.cycles)
+	state.Memory = Memory
+	state.PPU.Tilemap = emu.ppu.tilemap
+	state.PPU.Tiledata = emu.ppu.tiledata
+	state.PPU.Dots = emu.ppu.dots
+	state.PPU.Scanline = emu.ppu.scanline
+	state.PPU.Framebuffer = framebuffer
+	state.Joypad.PrevJoypad = emu.joypad.prev_joypad
+	state.Joypad.Directions = emu.joypad.directions
+	state.Joypad.Buttons = emu.joypad.buttons
+	state.Serial.Transferring = emu.serial.transferring
+	state.Serial.Clocksum = uint64(emu.serial.clocksum)
+	state.CGB.Enabled = cgb.enabled
+	state.CGB.Compat = cgb.compat
+	state.CGB.VRAM1 = cgb.vram1
+	state.CGB.WRAM = cgb.wram
+	state.CGB.BGPalettes = cgb.bg_palettes
+	state.CGB.OBJPalettes = cgb.obj_palettes
+	state.CGB.HDMA.Source = cgb.hdma.source
+	state.CGB.HDMA.Destination = cgb.hdma.destination
+	state.CGB.HDMA.Blocks = cgb.hdma.blocks
+	state.CGB.HDMA.Active = cgb.hdma.active
+	state.CGB.HDMA.Stall = uint64(cgb.hdma.stall)
+	state.SGB.Enabled = sgb.enabled
+	state.SGB.SelectBits = sgb.select_bits
+	state.SGB.Receiving = sgb.receiving
+	state.SGB.Bits = uint16(sgb.bits)
+	state.SGB.Packet = sgb.packet
+	state.SGB.Data = sgb.data
+	state.SGB.Packets = byte(sgb.packets)
+	state.SGB.Palettes = sgb.palettes
+	state.SGB.SystemPalettes = sgb.system_palettes
+	state.SGB.Attributes = sgb.attributes
+	state.SGB.AttributeFiles = sgb.attribute_files
+	state.SGB.Mask = sgb.mask
+	state.SGB.Frozen = sgb.frozen
+	state.SGB.TransferPending = sgb.transfer_pending
+	state.SGB.Transfer = sgb.transfer
+	state.SGB.TransferArg = sgb.transfer_arg
+	state.SGB.BorderTiles = sgb.border_tiles
+	state.SGB.BorderMap = sgb.border_map
+	state.SGB.BorderPalettes = sgb.border_palettes
+	state.SGB.Players = sgb.players
+	state.SGB.Player = sgb.player
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, state)
+}
+
+// Restores a state written by SaveState.
+// The emulator is left untouched if the state cannot be read.
+func (emu *Emulator) LoadState(r io.Reader) error {
+	var header saveStateHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if string(header.Magic[:]) != saveStateMagic {
+		return ErrNotSaveState
+	}
+	if header.Version != saveStateVersion {
+		return fmt.Errorf("unsupported save state version %d, expected %d", header.Version, saveStateVersion)
+	}
+
+	state := new(saveState)
+	if err := binary.Read(r, binary.LittleEndian, state); err != nil {
+		return err
+	}
+	if state.CallDepth > maxCallDepth {
+		return fmt.Errorf("save state with %d calls, at most %d are kept", state.CallDepth, maxCallDepth)
+	}
+
+	*emu.cpu.reg = state.Registers
+	*emu.cpu.flg = state.Flags
+	emu.cpu.pendingIME = state.PendingIME
+	emu.cpu.calls = append([]CallFrame(nil), state.Calls[:state.CallDepth]...)
+	emu.cpu.clk.div_clocksum = state.Clocks.DivClocksum
+	emu.cpu.clk.timer_clocksum = uint(state.Clocks.TimerClocksum)
+	emu.cpu.clk.cycles = uint(state.Clocks.Cycles)
+	Memory = state.Memory
+	emu.ppu.tilemap = state.PPU.Tilemap
+	emu.ppu.tiledata = state.PPU.Tiledata
+	emu.ppu.dots = state.PPU.Dots
+	emu.ppu.scanline = state.PPU.Scanline
+	framebuffer = state.PPU.Framebuffer
+	emu.joypad.prev_joypad = state.Joypad.PrevJoypad
+	emu.joypad.directions = state.Joypad.Directions
+	emu.joypad.buttons = state.Joypad.Buttons
+	emu.serial.transferring = state.Serial.Transferring
+	emu.serial.clocksum = uint(state.Serial.Clocksum)
+	cgb = cgbMemory{
+		enabled:      state.CGB.Enabled,
+		compat:       state.CGB.Compat,
+		vram1:        state.CGB.VRAM1,
+		wram:         state.CGB.WRAM,
+		bg_palettes:  state.CGB.BGPalettes,
+		obj_palettes: state.CGB.OBJPalettes,
+		hdma: vramDMA{
+			source:      state.CGB.HDMA.Source,
+			destination: state.CGB.HDMA.Destination,
+			blocks:      state.CGB.HDMA.Blocks,
+			active:      state.CGB.HDMA.Active,
+			stall:       uint(state.CGB.HDMA.Stall),
+		},
+	}
+	sgb = sgbState{
+		enabled:          state.SGB.Enabled,
+		select_bits:      state.SGB.SelectBits,
+		receiving:        state.SGB.Receiving,
+		bits:             int(state.SGB.Bits),
+		packet:           state.SGB.Packet,
+		data:             state.SGB.Data,
+		packets:          int(state.SGB.Packets),
+		palettes:         state.SGB.Palettes,
+		system_palettes:  state.SGB.SystemPalettes,
+		attributes:       state.SGB.Attributes,
+		attribute_files:  state.SGB.AttributeFiles,
+		mask:             state.SGB.Mask,
+		frozen:           state.SGB.Frozen,
+		transfer_pending: state.SGB.TransferPending,
+		transfer:         state.SGB.Transfer,
+		transfer_arg:     state.SGB.TransferArg,
+		border_tiles:     state.SGB.BorderTiles,
+		border_map:       state.SGB.BorderMap,
+		border_palettes:  state.SGB.BorderPalettes,
+		players:          state.SGB.Players,
+		player:           state.SGB.Player,
+	}
+	// the output is not saved, it is built from the rest
+	if sgb.enabled {
+		composeSGBScreen()
+	}
+
+	emu.rom_loaded = true
+	return nil
+}
@@ -0,0 +1,76 @@
+package maybego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	Memory = [65536]byte{}
+	emu := NewEmulator(logger)
+	emu.rom_loaded = true
+	emu.cpu.reg.A = 0x3F
+	emu.cpu.reg.PC = 0x1234
+	emu.cpu.flg.C = true
+	emu.cpu.pendingIME = true
+	emu.cpu.clk.timer_clocksum = 0x200
+	emu.ppu.dots = 123
+	emu.ppu.scanline = 42
+	emu.joypad.setButton(ButtonStart)
+	Write(0xC000, 0xAB)
+
+	var buffer bytes.Buffer
+	if err := emu.SaveState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	emu.Reset()
+	Memory = [65536]byte{}
+	emu.joypad.resetButton(ButtonStart)
+
+	if err := emu.LoadState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if emu.cpu.reg.A != 0x3F || emu.cpu.reg.PC != 0x1234 {
+		t.Errorf("Wrong registers. Got A: %.2X PC: %.4X, expected A: 3F PC: 1234", emu.cpu.reg.A, emu.cpu.reg.PC)
+	}
+	if !emu.cpu.flg.C || !emu.cpu.pendingIME {
+		t.Errorf("Wrong flags. Got C: %t pendingIME: %t, expected both to be set", emu.cpu.flg.C, emu.cpu.pendingIME)
+	}
+	if emu.cpu.clk.timer_clocksum != 0x200 {
+		t.Errorf("Current timer_clocksum: %x; expected: %x", emu.cpu.clk.timer_clocksum, 0x200)
+	}
+	if emu.ppu.dots != 123 || emu.ppu.scanline != 42 {
+		t.Errorf("Wrong PPU state. Got dots: %d scanline: %d, expected dots: 123 scanline: 42", emu.ppu.dots, emu.ppu.scanline)
+	}
+	if emu.joypad.buttons != 0x7 {
+		t.Errorf("Current buttons: %x; expected: %x", emu.joypad.buttons, 0x7)
+	}
+	if Read(0xC000) != 0xAB {
+		t.Errorf("Current Memory[0xC000]: %x; expected: %x", Read(0xC000), 0xAB)
+	}
+}
+
+func TestLoadStateRejectsOtherVersions(t *testing.T) {
+	emu := NewEmulator(logger)
+	emu.cpu.reg.PC = 0x150
+
+	var buffer bytes.Buffer
+	header := saveStateHeader{Version: saveStateVersion + 1}
+	copy(header.Magic[:], saveStateMagic)
+	binary.Write(&buffer, binary.LittleEndian, &header)
+	binary.Write(&buffer, binary.LittleEndian, new(saveState))
+
+	if err := emu.LoadState(&buffer); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+	if emu.cpu.reg.PC != 0x150 {
+		t.Errorf("Current PC: %x; expected the state to be untouched", emu.cpu.reg.PC)
+	}
+
+	if err := emu.LoadState(bytes.NewReader([]byte("not a state"))); err != ErrNotSaveState {
+		t.Errorf("Got error %v, expected %v", err, ErrNotSaveState)
+	}
+}
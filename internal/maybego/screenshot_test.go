@@ -0,0 +1,117 @@
+package maybego
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden images in testdata/screenshots")
+
+// frames to run before taking the screenshot, ROMs not listed here use screenshotDefaultFrames
+var screenshotFrames = map[string]int{
+	"dmg-acid2.gb": 60,
+}
+
+const screenshotDefaultFrames = 60
+
+var diffColor = color.RGBA{R: 0xFF, G: 0x00, B: 0x00, A: 0xFF}
+
+// Runs the emulator for the given number of frames and returns the last one.
+func runFramesToImage(emu *Emulator, frames int) *image.RGBA {
+	for range frames {
+		emu.RunFrame()
+	}
+	return emu.GetPPU().GetFrameImage()
+}
+
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, img)
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// Returns an image with every differing pixel marked in red and the number of those pixels.
+// Matching pixels are kept, but faded, so the differences stand out.
+func diffImages(actual image.Image, expected image.Image) (*image.RGBA, int) {
+	bounds := actual.Bounds().Union(expected.Bounds())
+	diff := image.NewRGBA(bounds)
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.RGBAModel.Convert(actual.At(x, y)).(color.RGBA)
+			e := color.RGBAModel.Convert(expected.At(x, y)).(color.RGBA)
+			if a != e {
+				diff.SetRGBA(x, y, diffColor)
+				count++
+				continue
+			}
+			diff.SetRGBA(x, y, color.RGBA{R: a.R/4 + 0xBF, G: a.G/4 + 0xBF, B: a.B/4 + 0xBF, A: 0xFF})
+		}
+	}
+	return diff, count
+}
+
+// Compares the screen of every ROM in testdata/screenshots with the PNG of the same name.
+// Run with -update to regenerate them.
+func TestScreenshots(t *testing.T) {
+	dir := filepath.Join("testdata", "screenshots")
+	roms, _ := filepath.Glob(filepath.Join(dir, "*.gb"))
+	if len(roms) == 0 {
+		t.Skip("no ROMs in testdata/screenshots")
+	}
+
+	for _, rom := range roms {
+		name := filepath.Base(rom)
+		t.Run(name, func(t *testing.T) {
+			frames, ok := screenshotFrames[name]
+			if !ok {
+				frames = screenshotDefaultFrames
+			}
+
+			actual := runFramesToImage(loadTestRom(t, rom), frames)
+			base := strings.TrimSuffix(rom, filepath.Ext(rom))
+			golden := base + ".png"
+
+			if *update {
+				if err := writePNG(golden, actual); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := readPNG(golden)
+			if err != nil {
+				t.Fatalf("Could not read golden image (run with -update to create it): %v", err)
+			}
+
+			diff, count := diffImages(actual, expected)
+			if count == 0 {
+				return
+			}
+
+			writePNG(base+"-actual.png", actual)
+			writePNG(base+"-diff.png", diff)
+			t.Errorf("%d pixels differ from %s, see %s", count, golden, base+"-diff.png")
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package maybego
+
+const (
+	SB uint16 = 0xFF01 // Serial transfer data
+	SC uint16 = 0xFF02 // Serial transfer control
+	// one bit every 128 cpu cycles with the internal clock (8192 Hz)
+	SERIAL_TRANSFER_CYCLES uint = 8 * 128
+)
+
+type Serial struct {
+	output       []byte
+	transferring bool
+	clocksum     uint
+	link         LinkCable // nil if nothing is connected
+}
+
+func NewSerial() *Serial {
+	Write(SB, 0x00)
+	Write(SC, 0x7E)
+	return &Serial{}
+}
+
+// Returns every byte transmitted so far.
+func (serial *Serial) GetOutput() []byte {
+	return serial.output
+}
+
+func (serial *Serial) update(cycles byte) {
+	if serial.link != nil {
+		serial.link.poll(serial)
+	}
+
+	control := Read(SC)
+	start_requested := control&0x80 != 0
+	internal_clock := control&0x01 != 0
+
+	if !serial.transferring {
+		// transfers with an external clock are finished by the link partner, see receive
+		if !start_requested || !internal_clock {
+			return
+		}
+		serial.transferring = true
+		serial.clocksum = 0
+	}
+
+	serial.clocksum += uint(cycles)
+	if serial.clocksum < SERIAL_TRANSFER_CYCLES {
+		return
+	}
+
+	out := Read(SB)
+	in := byte(0xFF) // nothing connected, so only ones are shifted in
+	if serial.link != nil {
+		// the transfer stays busy until the other side answered
+		var done bool
+		if in, done = serial.link.exchange(out); !done {
+			return
+		}
+	}
+
+	serial.output = append(serial.output, out)
+	serial.transferring = false
+	serial.clocksum = 0
+
+	Write(SB, in)
+	Write(SC, control&0x7F)
+	RequestInterrupt(3)
+}
+
+// Finishes a transfer clocked by the link partner, which sent in.
+// mem is the address space of this side, it is not the global one while the
+// emulator is parked by a LocalLink.
+// Returns the byte shifted out, 0xFF if no transfer with an external clock was started.
+func (serial *Serial) receive(mem *[65536]byte, in byte) byte {
+	control := mem[SC]
+	if control&0x81 != 0x80 {
+		return 0xFF
+	}
+
+	out := mem[SB]
+	serial.output = append(serial.output, out)
+	mem[SB] = in
+	mem[SC] = control & 0x7F
+	mem[IF] |= 1 << 3
+	return out
+}
+
+func (serial *Serial) Reset() {
+	serial.output = nil
+	serial.transferring = false
+	serial.clocksum = 0
+}
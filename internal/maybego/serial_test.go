@@ -0,0 +1,84 @@
+package maybego
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// two emulated minutes, the full cpu_instrs takes about one
+const blarggTimeout uint = 2 * 60 * 1048576
+
+func TestSerialTransfer(t *testing.T) {
+	serial := NewSerial()
+	Write(IF, 0x0)
+	Write(SB, 'P')
+	Write(SC, 0x81)
+
+	for range SERIAL_TRANSFER_CYCLES - 1 {
+		serial.update(1)
+	}
+	if len(serial.GetOutput()) != 0 {
+		t.Errorf("Transfer finished after %d cycles, expected %d", SERIAL_TRANSFER_CYCLES-1, SERIAL_TRANSFER_CYCLES)
+	}
+
+	serial.update(1)
+	if string(serial.GetOutput()) != "P" {
+		t.Errorf("Got output %q, expected %q", serial.GetOutput(), "P")
+	}
+	if Read(SC)&0x80 != 0 {
+		t.Errorf("Wrong SC. Got %.2X, expected transfer bit to be reset", Read(SC))
+	}
+	if Read(SB) != 0xFF {
+		t.Errorf("Wrong SB. Got %.2X, expected FF", Read(SB))
+	}
+	if Read(IF)&0x8 == 0 {
+		t.Errorf("Wrong IF. Got %.2X, expected serial interrupt", Read(IF))
+	}
+}
+
+func TestSerialExternalClock(t *testing.T) {
+	serial := NewSerial()
+	Write(IF, 0x0)
+	Write(SB, 'P')
+	Write(SC, 0x80)
+
+	for range SERIAL_TRANSFER_CYCLES {
+		serial.update(1)
+	}
+
+	if len(serial.GetOutput()) != 0 {
+		t.Errorf("Got output %q without a clock", serial.GetOutput())
+	}
+	if Read(SC) != 0x80 {
+		t.Errorf("Wrong SC. Got %.2X, expected 80", Read(SC))
+	}
+	if Read(IF)&0x8 != 0 {
+		t.Errorf("Wrong IF. Got %.2X, expected no serial interrupt", Read(IF))
+	}
+}
+
+// Runs every ROM in testdata/blargg and checks the text it prints over serial.
+func TestBlargg(t *testing.T) {
+	roms, _ := filepath.Glob(filepath.Join("testdata", "blargg", "*.gb"))
+	if len(roms) == 0 {
+		t.Skip("no ROMs in testdata/blargg")
+	}
+
+	for _, rom := range roms {
+		t.Run(filepath.Base(rom), func(t *testing.T) {
+			emu := loadTestRom(t, rom)
+			for emu.GetCPUState().cycles < blarggTimeout {
+				emu.RunFrame()
+				output := string(emu.GetSerial().GetOutput())
+				if strings.Contains(output, "Passed") {
+					return
+				}
+				if strings.Contains(output, "Failed") {
+					t.Fatalf("Serial output:\n%s", output)
+				}
+			}
+			t.Fatalf("Timed out after %d cycles. Serial output:\n%s", blarggTimeout, emu.GetSerial().GetOutput())
+		})
+	}
+}
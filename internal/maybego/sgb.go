@@ -0,0 +1,475 @@
+package maybego
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+const (
+	// 0x03 for games with Super Game Boy functions
+	SGB_FLAG uint16 = 0x146
+	// the SGB flag only counts with 0x33, which points to the new licensee code
+	OLD_LICENSEE uint16 = 0x14B
+)
+
+// The SGB output, the game screen sits in the middle of the border.
+const (
+	SGB_WIDTH  = 256
+	SGB_HEIGHT = 224
+	sgbScreenX = 48
+	sgbScreenY = 40
+)
+
+// SGB commands, the upper 5 bits of the first byte of a packet.
+// The sound, icon and program commands are ignored.
+const (
+	SGB_PAL01    byte = 0x00
+	SGB_PAL23    byte = 0x01
+	SGB_PAL03    byte = 0x02
+	SGB_PAL12    byte = 0x03
+	SGB_ATTR_BLK byte = 0x04
+	SGB_ATTR_LIN byte = 0x05
+	SGB_ATTR_DIV byte = 0x06
+	SGB_ATTR_CHR byte = 0x07
+	SGB_PAL_SET  byte = 0x0A
+	SGB_PAL_TRN  byte = 0x0B
+	SGB_MLT_REQ  byte = 0x11
+	SGB_CHR_TRN  byte = 0x13
+	SGB_PCT_TRN  byte = 0x14
+	SGB_ATTR_TRN byte = 0x15
+	SGB_ATTR_SET byte = 0x16
+	SGB_MASK_EN  byte = 0x17
+)
+
+// What MASK_EN shows instead of the game screen
+const (
+	sgbMaskNone   byte = 0
+	sgbMaskFreeze byte = 1 // the last frame before the mask
+	sgbMaskBlack  byte = 2
+	sgbMaskColor0 byte = 3
+)
+
+// The palette the SGB gives games until they set their own
+var sgbDefaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// The Super Game Boy. Games send it packets of 16 bytes bit by bit through JOYP,
+// commands with more data show it on screen for a frame (the *_TRN commands).
+// Colors are RGB555 like on the CGB.
+type sgbState struct {
+	enabled bool
+
+	// P14 and P15 of the last JOYP write
+	select_bits byte
+	receiving   bool
+	bits        int
+	packet      [16]byte
+	// a command has up to 7 packets
+	data    [7 * 16]byte
+	packets int
+
+	// color 0 is shared by all four palettes
+	palettes        [4][4]uint16
+	system_palettes [512][4]uint16
+	// palette of every tile of the screen
+	attributes      [20 * 18]byte
+	attribute_files [45][90]byte
+	mask            byte
+	frozen          [160 * 144]uint16
+
+	// a *_TRN command waiting for the end of the frame
+	transfer_pending bool
+	transfer         byte
+	transfer_arg     byte
+
+	// 256 tiles with 4 bits per pixel and a 32x28 map, using palettes 4-7
+	border_tiles    [256 * 32]byte
+	border_map      [32 * 32]uint16
+	border_palettes [4][16]uint16
+
+	// MLT_REQ: 1, 2 or 4 joypads and the one JOYP reads
+	players byte
+	player  byte
+
+	screen [SGB_WIDTH * SGB_HEIGHT]uint16
+}
+
+var sgb sgbState
+
+// Returns true while emulating a Super Game Boy.
+func (emu *Emulator) IsSGB() bool {
+	return sgb.enabled
+}
+
+// Returns the output of the last frame in RGB555: the game screen inside the border.
+func (emu *Emulator) GetSGBFrame() *[SGB_WIDTH * SGB_HEIGHT]uint16 {
+	return &sgb.screen
+}
+
+func (emu *Emulator) GetSGBFrameImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, SGB_WIDTH, SGB_HEIGHT))
+	for y := range SGB_HEIGHT {
+		for x := range SGB_WIDTH {
+			img.SetRGBA(x, y, rgba(sgb.screen[SGB_WIDTH*y+x]))
+		}
+	}
+	return img
+}
+
+func sgbRom(rom []byte) bool {
+	return len(rom) > int(OLD_LICENSEE) && rom[SGB_FLAG] == 0x03 && rom[OLD_LICENSEE] == 0x33
+}
+
+// Puts the SGB into the state the game finds after boot.
+func resetSGB() {
+	sgb = sgbState{enabled: true, players: 1}
+	for i := range sgb.palettes {
+		sgb.palettes[i] = sgbDefaultPalette
+	}
+	composeSGBScreen()
+}
+
+// Called for every JOYP write. Pulling P14 and P15 low together starts a packet,
+// afterwards every pulse of P14 sends a 0 bit and every pulse of P15 a 1 bit,
+// lowest bit first. A 0 bit ends the packet after 128 bits.
+func sgbWrite(val byte) {
+	selected := val & 0x30
+	previous := sgb.select_bits
+	sgb.select_bits = selected
+
+	if selected == 0x00 {
+		sgb.receiving = true
+		sgb.bits = 0
+		sgb.packet = [16]byte{}
+		return
+	}
+	if !sgb.receiving {
+		// P15 going high selects the next joypad
+		if sgb.players > 1 && previous&0x20 == 0 && selected&0x20 != 0 {
+			sgb.player = (sgb.player + 1) & (sgb.players - 1)
+		}
+		return
+	}
+	// a bit is only sent by a pulse from both high
+	if previous != 0x30 || selected == 0x30 {
+		return
+	}
+
+	bit := selected == 0x10
+	if sgb.bits == 128 {
+		sgb.receiving = false
+		if !bit {
+			receiveSGBPacket()
+		}
+		return
+	}
+	if bit {
+		sgb.packet[sgb.bits/8] |= 1 << (sgb.bits % 8)
+	}
+	sgb.bits++
+}
+
+// The lower 3 bits of the first byte give the number of packets of the command.
+func receiveSGBPacket() {
+	if sgb.packets == 0 && sgb.packet[0]&0x7 == 0 {
+		return
+	}
+	copy(sgb.data[sgb.packets*16:], sgb.packet[:])
+	sgb.packets++
+	length := int(sgb.data[0] & 0x7)
+	if sgb.packets < length {
+		return
+	}
+	sgb.packets = 0
+	runSGBCommand(sgb.data[:length*16])
+}
+
+func runSGBCommand(data []byte) {
+	switch command := data[0] >> 3; command {
+	case SGB_PAL01:
+		setSGBPalettes(0, 1, data)
+	case SGB_PAL23:
+		setSGBPalettes(2, 3, data)
+	case SGB_PAL03:
+		setSGBPalettes(0, 3, data)
+	case SGB_PAL12:
+		setSGBPalettes(1, 2, data)
+	case SGB_ATTR_BLK:
+		sgbAttributeBlocks(data)
+	case SGB_ATTR_LIN:
+		sgbAttributeLines(data)
+	case SGB_ATTR_DIV:
+		sgbAttributeDivide(data)
+	case SGB_ATTR_CHR:
+		sgbAttributeTiles(data)
+	case SGB_PAL_SET:
+		for i := range sgb.palettes {
+			index := binary.LittleEndian.Uint16(data[1+i*2:]) & 0x1FF
+			sgb.palettes[i] = sgb.system_palettes[index]
+		}
+		setSGBColor0(sgb.palettes[0][0])
+		sgbAttributeSet(data[9])
+	case SGB_ATTR_SET:
+		sgbAttributeSet(data[1] | 0x80)
+	case SGB_MLT_REQ:
+		sgb.players = [4]byte{1, 2, 1, 4}[data[1]&0x3]
+		sgb.player = 0
+		if busJoypad != nil {
+			busJoypad.update()
+		}
+	case SGB_MASK_EN:
+		mask := data[1] & 0x3
+		if mask == sgbMaskFreeze && sgb.mask != sgbMaskFreeze {
+			sgb.frozen = framebuffer
+		}
+		sgb.mask = mask
+	case SGB_PAL_TRN, SGB_CHR_TRN, SGB_PCT_TRN, SGB_ATTR_TRN:
+		sgb.transfer_pending = true
+		sgb.transfer = command
+		sgb.transfer_arg = data[1]
+	}
+}
+
+func sgbColor(data []byte, index int) uint16 {
+	return binary.LittleEndian.Uint16(data[index*2:]) & 0x7FFF
+}
+
+func setSGBColor0(color uint16) {
+	for i := range sgb.palettes {
+		sgb.palettes[i][0] = color
+	}
+}
+
+// PALxy: color 0, colors 1-3 of palette x, colors 1-3 of palette y.
+func setSGBPalettes(x, y int, data []byte) {
+	colors := data[1:]
+	setSGBColor0(sgbColor(colors, 0))
+	for i := 1; i < 4; i++ {
+		sgb.palettes[x][i] = sgbColor(colors, i)
+		sgb.palettes[y][i] = sgbColor(colors, i+3)
+	}
+}
+
+func setSGBAttribute(x, y int, palette byte) {
+	if x >= 0 && x < 20 && y >= 0 && y < 18 {
+		sgb.attributes[y*20+x] = palette & 0x3
+	}
+}
+
+// The palette of a pixel of the game screen.
+func sgbAttribute(x, y int) byte {
+	return sgb.attributes[(y/8)*20+x/8]
+}
+
+// ATTR_BLK: rectangles, each with a palette for the tiles inside, on and outside its border.
+// The control byte selects which of them change.
+func sgbAttributeBlocks(data []byte) {
+	sets := min(int(data[1]&0x1F), (len(data)-2)/6)
+	for i := range sets {
+		set := data[2+i*6:]
+		control := set[0] & 0x7
+		inside, border, outside := set[1]&0x3, (set[1]>>2)&0x3, (set[1]>>4)&0x3
+		x1, y1, x2, y2 := int(set[2]&0x1F), int(set[3]&0x1F), int(set[4]&0x1F), int(set[5]&0x1F)
+		// with only the inside or the outside selected, the border goes along with it
+		switch control {
+		case 0x1:
+			control, border = 0x3, inside
+		case 0x4:
+			control, border = 0x6, outside
+		}
+
+		for y := range 18 {
+			for x := range 20 {
+				switch {
+				case x > x1 && x < x2 && y > y1 && y < y2:
+					if control&0x1 != 0 {
+						setSGBAttribute(x, y, inside)
+					}
+				case x >= x1 && x <= x2 && y >= y1 && y <= y2:
+					if control&0x2 != 0 {
+						setSGBAttribute(x, y, border)
+					}
+				default:
+					if control&0x4 != 0 {
+						setSGBAttribute(x, y, outside)
+					}
+				}
+			}
+		}
+	}
+}
+
+// ATTR_LIN: whole rows (bit 7 set) or columns of tiles.
+func sgbAttributeLines(data []byte) {
+	lines := min(int(data[1]), len(data)-2)
+	for _, line := range data[2 : 2+lines] {
+		number, palette := int(line&0x1F), (line>>5)&0x3
+		for i := range 20 {
+			if line&0x80 != 0 {
+				setSGBAttribute(i, number, palette)
+			} else {
+				setSGBAttribute(number, i, palette)
+			}
+		}
+	}
+}
+
+// ATTR_DIV: splits the screen at a column, or a row if bit 6 is set,
+// into a palette before, on and after it.
+func sgbAttributeDivide(data []byte) {
+	mode, line := data[1], int(data[2]&0x1F)
+	for y := range 18 {
+		for x := range 20 {
+			position := x
+			if mode&0x40 != 0 {
+				position = y
+			}
+			switch {
+			case position < line:
+				setSGBAttribute(x, y, mode>>2)
+			case position == line:
+				setSGBAttribute(x, y, mode>>4)
+			default:
+				setSGBAttribute(x, y, mode)
+			}
+		}
+	}
+}
+
+// ATTR_CHR: palettes for consecutive tiles from a start tile, 4 per byte with the
+// highest bits first, going right or (with byte 5 set) down.
+func sgbAttributeTiles(data []byte) {
+	x, y := int(data[1]&0x1F), int(data[2]&0x1F)
+	count := min(int(binary.LittleEndian.Uint16(data[3:])), 20*18, (len(data)-6)*4)
+	vertical := data[5]&0x1 != 0
+	for i := range count {
+		setSGBAttribute(x, y, data[6+i/4]>>(6-(i%4)*2))
+		if vertical {
+			if y++; y >= 18 {
+				y, x = 0, x+1
+			}
+		} else {
+			if x++; x >= 20 {
+				x, y = 0, y+1
+			}
+		}
+	}
+}
+
+// Bits 0-5 select an attribute file of ATTR_TRN if bit 7 is set, bit 6 cancels the mask.
+func sgbAttributeSet(flags byte) {
+	if file := int(flags & 0x3F); flags&0x80 != 0 && file < len(sgb.attribute_files) {
+		for i := range sgb.attributes {
+			sgb.attributes[i] = (sgb.attribute_files[file][i/4] >> (6 - (i%4)*2)) & 0x3
+		}
+	}
+	if flags&0x40 != 0 {
+		sgb.mask = sgbMaskNone
+	}
+}
+
+// Called when the PPU finished a frame. Pending transfers read what it showed.
+func sgbFrame() {
+	if sgb.transfer_pending {
+		sgb.transfer_pending = false
+		sgbTransfer(sgb.transfer, sgb.transfer_arg, sgbScreenData())
+	}
+	composeSGBScreen()
+}
+
+// The 4 KiB a *_TRN command receives: the tile data of the first 256 tiles
+// of the BG map, row by row, 20 tiles per row.
+func sgbScreenData() []byte {
+	lcdc := Memory[LCDC]
+	tilemap := uint16(0x9800)
+	if lcdc&0x8 != 0 {
+		tilemap = 0x9C00
+	}
+
+	data := make([]byte, 0x1000)
+	for i := range 256 {
+		tileID := Memory[tilemap+uint16(i/20)*32+uint16(i%20)]
+		address := 0x8000 + uint16(tileID)*0x10
+		if lcdc&0x10 == 0 {
+			address = uint16(0x9000 + int(int8(tileID))*0x10)
+		}
+		copy(data[i*0x10:], Memory[address:address+0x10])
+	}
+	return data
+}
+
+func sgbTransfer(command byte, arg byte, data []byte) {
+	switch command {
+	case SGB_PAL_TRN:
+		for i := range sgb.system_palettes {
+			for c := range 4 {
+				sgb.system_palettes[i][c] = sgbColor(data, i*4+c)
+			}
+		}
+	case SGB_CHR_TRN:
+		// bit 0 selects the upper 128 tiles
+		copy(sgb.border_tiles[int(arg&0x1)*0x1000:], data)
+	case SGB_PCT_TRN:
+		for i := range sgb.border_map {
+			sgb.border_map[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+		for p := range sgb.border_palettes {
+			for c := range 16 {
+				sgb.border_palettes[p][c] = sgbColor(data[0x800:], p*16+c)
+			}
+		}
+	case SGB_ATTR_TRN:
+		for i := range sgb.attribute_files {
+			copy(sgb.attribute_files[i][:], data[i*90:])
+		}
+	}
+}
+
+// Draws the border and the game screen, or what the mask shows instead, into the output.
+// Color 0 of the border is transparent and shows color 0 of the game palettes.
+func composeSGBScreen() {
+	backdrop := sgb.palettes[0][0]
+
+	for tile_y := range 28 {
+		for tile_x := range 32 {
+			entry := sgb.border_map[tile_y*32+tile_x]
+			tile := int(entry & 0xFF)
+			// palettes 4-7 in bits 10-12
+			palette := (entry >> 10) & 0x3
+			for y := range 8 {
+				row := y
+				if entry&0x8000 != 0 {
+					row = 7 - y
+				}
+				planes := sgb.border_tiles[tile*32+row*2:]
+				for x := range 8 {
+					bit := 7 - x
+					if entry&0x4000 != 0 {
+						bit = x
+					}
+					index := planes[0]>>bit&0x1 | (planes[1]>>bit&0x1)<<1 |
+						(planes[16]>>bit&0x1)<<2 | (planes[17]>>bit&0x1)<<3
+					color := backdrop
+					if index != 0 {
+						color = sgb.border_palettes[palette][index]
+					}
+					sgb.screen[(tile_y*8+y)*SGB_WIDTH+tile_x*8+x] = color
+				}
+			}
+		}
+	}
+
+	for y := range 144 {
+		for x := range 160 {
+			color := framebuffer[y*160+x]
+			switch sgb.mask {
+			case sgbMaskFreeze:
+				color = sgb.frozen[y*160+x]
+			case sgbMaskBlack:
+				color = 0
+			case sgbMaskColor0:
+				color = backdrop
+			}
+			sgb.screen[(y+sgbScreenY)*SGB_WIDTH+x+sgbScreenX] = color
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package maybego
+
+import (
+	"testing"
+)
+
+// Loads an empty ROM flagged for the SGB, sgb is reset once the test is done.
+func loadSgbRom(t *testing.T) *Emulator {
+	t.Helper()
+	t.Cleanup(func() { sgb = sgbState{} })
+
+	rom := programRom()
+	rom[SGB_FLAG] = 0x03
+	rom[OLD_LICENSEE] = 0x33
+	return loadProgram(rom, ModelAuto)
+}
+
+// Sends a packet through JOYP like a game: a reset pulse, 128 bits and the stop bit.
+func sendSgbPacket(packet [16]byte) {
+	Write(JOYP, 0x00)
+	Write(JOYP, 0x30)
+	for i := range 129 {
+		bit := i < 128 && packet[i/8]>>(i%8)&0x1 != 0
+		if bit {
+			Write(JOYP, 0x10)
+		} else {
+			Write(JOYP, 0x20)
+		}
+		Write(JOYP, 0x30)
+	}
+}
+
+func sgbPacket(command byte, data ...byte) [16]byte {
+	var packet [16]byte
+	packet[0] = command<<3 | 1
+	copy(packet[1:], data)
+	return packet
+}
+
+func TestSelectModelSGB(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     byte
+		licensee byte
+		cgb_flag byte
+		model    Model
+		expected bool
+	}{
+		{"flagged", 0x03, 0x33, 0x00, ModelAuto, true},
+		{"old licensee", 0x03, 0x01, 0x00, ModelAuto, false},
+		{"cgb game", 0x03, 0x33, 0x80, ModelAuto, false},
+		{"forced dmg", 0x03, 0x33, 0x00, ModelDMG, false},
+		{"forced sgb", 0x00, 0x00, 0x00, ModelSGB, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Cleanup(func() { sgb = sgbState{}; cgb = cgbMemory{} })
+			rom := programRom()
+			rom[SGB_FLAG] = test.flag
+			rom[OLD_LICENSEE] = test.licensee
+			rom[CGB_FLAG] = test.cgb_flag
+			emu := loadProgram(rom, test.model)
+
+			if emu.IsSGB() != test.expected {
+				t.Errorf("Got SGB %t, expected %t", emu.IsSGB(), test.expected)
+			}
+			if test.expected && (emu.cpu.reg.C != 0x14 || emu.cpu.reg.H != 0xC0 || emu.cpu.reg.L != 0x60) {
+				t.Errorf("Current C, H, L: %x %x %x; expected: 14 c0 60", emu.cpu.reg.C, emu.cpu.reg.H, emu.cpu.reg.L)
+			}
+		})
+	}
+}
+
+func TestSgbPalettes(t *testing.T) {
+	loadSgbRom(t)
+
+	// color 0, then colors 1-3 of palette 2 and of palette 3
+	sendSgbPacket(sgbPacket(SGB_PAL23, 0x1F, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 0x05, 0x00, 0x06, 0x80))
+
+	expected := [4][4]uint16{
+		{0x1F, sgbDefaultPalette[1], sgbDefaultPalette[2], sgbDefaultPalette[3]},
+		{0x1F, sgbDefaultPalette[1], sgbDefaultPalette[2], sgbDefaultPalette[3]},
+		{0x1F, 0x01, 0x02, 0x03},
+		{0x1F, 0x04, 0x05, 0x06},
+	}
+	if sgb.palettes != expected {
+		t.Errorf("Current palettes: %x; expected: %x", sgb.palettes, expected)
+	}
+}
+
+func TestSgbPacketStopBit(t *testing.T) {
+	loadSgbRom(t)
+
+	packet := sgbPacket(SGB_MASK_EN, sgbMaskBlack)
+	Write(JOYP, 0x00)
+	Write(JOYP, 0x30)
+	for i := range 129 {
+		// a 1 as stop bit drops the packet
+		if i == 128 || packet[i/8]>>(i%8)&0x1 != 0 {
+			Write(JOYP, 0x10)
+		} else {
+			Write(JOYP, 0x20)
+		}
+		Write(JOYP, 0x30)
+	}
+	if sgb.mask != sgbMaskNone {
+		t.Errorf("Current mask: %d; expected: %d", sgb.mask, sgbMaskNone)
+	}
+
+	sendSgbPacket(packet)
+	if sgb.mask != sgbMaskBlack {
+		t.Errorf("Current mask: %d; expected: %d", sgb.mask, sgbMaskBlack)
+	}
+}
+
+func TestSgbAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets [][16]byte
+		// palettes of the tiles at (0,0), (5,5), (3,4), (10,10) and (19,17)
+		expected [5]byte
+	}{
+		{"ATTR_BLK inside and border", [][16]byte{sgbPacket(SGB_ATTR_BLK, 1, 0x3, 0x1|0x2<<2, 3, 3, 7, 7)}, [5]byte{0, 1, 2, 0, 0}},
+		{"ATTR_BLK only outside", [][16]byte{sgbPacket(SGB_ATTR_BLK, 1, 0x4, 0x3<<4, 3, 3, 7, 7)}, [5]byte{3, 0, 3, 3, 3}},
+		{"ATTR_LIN", [][16]byte{sgbPacket(SGB_ATTR_LIN, 2, 0x80|2<<5|5, 10|1<<5)}, [5]byte{0, 2, 0, 1, 0}},
+		{"ATTR_DIV rows", [][16]byte{sgbPacket(SGB_ATTR_DIV, 0x40|3<<4|1<<2|2, 5)}, [5]byte{1, 3, 1, 2, 2}},
+		{"ATTR_CHR", [][16]byte{sgbPacket(SGB_ATTR_CHR, 2, 4, 3, 0, 0, 0x1<<6|0x2<<4|0x3<<2)}, [5]byte{0, 0, 2, 0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loadSgbRom(t)
+			for _, packet := range test.packets {
+				sendSgbPacket(packet)
+			}
+			var actual [5]byte
+			for i, tile := range [5][2]int{{0, 0}, {5, 5}, {3, 4}, {10, 10}, {19, 17}} {
+				actual[i] = sgbAttribute(tile[0]*8, tile[1]*8)
+			}
+			if actual != test.expected {
+				t.Errorf("Current palettes: %v; expected: %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestSgbMultiplayer(t *testing.T) {
+	loadSgbRom(t)
+
+	Write(JOYP, 0x30)
+	if Read(JOYP)&0xF != 0xF {
+		t.Errorf("Current JOYP: %x; expected: %x", Read(JOYP), 0xFF)
+	}
+
+	sendSgbPacket(sgbPacket(SGB_MLT_REQ, 0x01))
+	// each time P15 goes high again the next joypad is selected
+	for _, expected := range []byte{0xE, 0xF, 0xE} {
+		Write(JOYP, 0x10)
+		Write(JOYP, 0x30)
+		if Read(JOYP)&0xF != expected {
+			t.Errorf("Current JOYP: %x; expected lines: %x", Read(JOYP), expected)
+		}
+	}
+}
+
+// Fills the tiles shown by the BG map with data, as a game does for a *_TRN command.
+func showSgbData(data []byte) {
+	Memory[LCDC] = 0x91
+	for i := range 256 {
+		Memory[0x9800+(i/20)*32+i%20] = byte(i)
+	}
+	copy(Memory[0x8000:0x9000], data)
+}
+
+func TestSgbBorder(t *testing.T) {
+	loadSgbRom(t)
+
+	// tile 1: color 1 in the top left pixel, color 15 in the one next to it
+	tiles := make([]byte, 0x1000)
+	tiles[32] = 0xC0
+	tiles[33] = 0x40
+	tiles[48] = 0x40
+	tiles[49] = 0x40
+	showSgbData(tiles)
+	sendSgbPacket(sgbPacket(SGB_CHR_TRN, 0))
+	sgbFrame()
+
+	// tile 1 with palette 5 in the top left corner, flipped in the one next to it
+	picture := make([]byte, 0x1000)
+	picture[0], picture[1] = 0x01, 0x05<<2
+	picture[2], picture[3] = 0x01, 0x05<<2|0x40
+	picture[0x800+32+2], picture[0x800+32+3] = 0x1F, 0x00
+	picture[0x800+32+30], picture[0x800+32+31] = 0x00, 0x7C
+	showSgbData(picture)
+	sendSgbPacket(sgbPacket(SGB_PCT_TRN))
+	sgbFrame()
+
+	tests := []struct {
+		x, y     int
+		expected uint16
+	}{
+		{0, 0, 0x1F},
+		{1, 0, 0x7C00},
+		{2, 0, sgbDefaultPalette[0]},
+		{15, 0, 0x1F},
+		{14, 0, 0x7C00},
+	}
+	for _, test := range tests {
+		actual := sgb.screen[test.y*SGB_WIDTH+test.x]
+		if actual != test.expected {
+			t.Errorf("Pixel (%d, %d): %x; expected: %x", test.x, test.y, actual, test.expected)
+		}
+	}
+}
+
+func TestSgbMask(t *testing.T) {
+	loadSgbRom(t)
+	framebuffer[0] = 0x1234
+	center := sgbScreenY*SGB_WIDTH + sgbScreenX
+
+	tests := []struct {
+		mask     byte
+		expected uint16
+	}{
+		{sgbMaskFreeze, 0x1234},
+		{sgbMaskBlack, 0x0000},
+		{sgbMaskColor0, sgbDefaultPalette[0]},
+		{sgbMaskNone, 0x4321},
+	}
+	for _, test := range tests {
+		sendSgbPacket(sgbPacket(SGB_MASK_EN, test.mask))
+		framebuffer[0] = 0x4321
+		sgbFrame()
+		if sgb.screen[center] != test.expected {
+			t.Errorf("Mask %d: current pixel: %x; expected: %x", test.mask, sgb.screen[center], test.expected)
+		}
+	}
+}
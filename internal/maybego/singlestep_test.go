@@ -0,0 +1,122 @@
+package maybego
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Directory with the single-step JSON tests (one file per opcode, e.g. "3e.json", "cb 7f.json").
+// Defaults to testdata/sm83.
+const singleStepDirEnv = "MAYBEGO_SINGLESTEP_DIR"
+
+type singleStepState struct {
+	PC  uint16      `json:"pc"`
+	SP  uint16      `json:"sp"`
+	A   byte        `json:"a"`
+	B   byte        `json:"b"`
+	C   byte        `json:"c"`
+	D   byte        `json:"d"`
+	E   byte        `json:"e"`
+	F   byte        `json:"f"`
+	H   byte        `json:"h"`
+	L   byte        `json:"l"`
+	IME byte        `json:"ime"`
+	RAM [][2]uint16 `json:"ram"`
+}
+
+type singleStepTest struct {
+	Name    string            `json:"name"`
+	Initial singleStepState   `json:"initial"`
+	Final   singleStepState   `json:"final"`
+	Cycles  []json.RawMessage `json:"cycles"`
+}
+
+func setSingleStepState(state *singleStepState) {
+	Memory = [65536]byte{}
+	for _, ram := range state.RAM {
+		Write(ram[0], byte(ram[1]))
+	}
+
+	cpu.reg.PC = state.PC
+	cpu.reg.SP = state.SP
+	cpu.reg.A = state.A
+	cpu.reg.B = state.B
+	cpu.reg.C = state.C
+	cpu.reg.D = state.D
+	cpu.reg.E = state.E
+	cpu.reg.H = state.H
+	cpu.reg.L = state.L
+	cpu.BytesToFlags(state.F)
+	cpu.flg.IME = state.IME != 0
+	cpu.flg.HALT = false
+	cpu.pendingIME = false
+}
+
+// Returns a description of every difference between the cpu and the expected state.
+func compareSingleStepState(state *singleStepState) []string {
+	var diffs []string
+	compare := func(name string, actual uint16, expected uint16) {
+		if actual != expected {
+			diffs = append(diffs, fmt.Sprintf("%s: got %.2X, expected %.2X", name, actual, expected))
+		}
+	}
+
+	compare("PC", cpu.reg.PC, state.PC)
+	compare("SP", cpu.reg.SP, state.SP)
+	compare("A", uint16(cpu.reg.A), uint16(state.A))
+	compare("B", uint16(cpu.reg.B), uint16(state.B))
+	compare("C", uint16(cpu.reg.C), uint16(state.C))
+	compare("D", uint16(cpu.reg.D), uint16(state.D))
+	compare("E", uint16(cpu.reg.E), uint16(state.E))
+	compare("F", uint16(cpu.FlagsToBytes()), uint16(state.F))
+	compare("H", uint16(cpu.reg.H), uint16(state.H))
+	compare("L", uint16(cpu.reg.L), uint16(state.L))
+	compare("IME", uint16(FlagToBit(cpu.flg.IME || cpu.pendingIME)), uint16(state.IME))
+	for _, ram := range state.RAM {
+		compare(fmt.Sprintf("[%.4X]", ram[0]), uint16(Read(ram[0])), ram[1])
+	}
+
+	return diffs
+}
+
+func TestSingleStep(t *testing.T) {
+	dir := os.Getenv(singleStepDirEnv)
+	if dir == "" {
+		dir = filepath.Join("testdata", "sm83")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) == 0 {
+		t.Skipf("no single-step tests in %s (set %s)", dir, singleStepDirEnv)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tests []singleStepTest
+			if err := json.Unmarshal(data, &tests); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, test := range tests {
+				setSingleStepState(&test.Initial)
+				cycles := cpu.opcodes[Read(cpu.reg.PC)]()
+
+				diffs := compareSingleStepState(&test.Final)
+				if int(cycles) != len(test.Cycles) {
+					diffs = append(diffs, fmt.Sprintf("cycles: got %d, expected %d", cycles, len(test.Cycles)))
+				}
+				if len(diffs) > 0 {
+					// the first failing case per opcode is usually enough to find the bug
+					t.Fatalf("%s\n%s", test.Name, strings.Join(diffs, "\n"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package maybego
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Labels of a ROM from a symbol file as written by RGBDS or wla-gb:
+// "BB:AAAA Name" per line, comments start with ";".
+// Without an MBC only ROM bank 1 is mapped at 4000-7FFF, symbols of other
+// ROM banks are skipped. Symbols in RAM are used whatever their bank.
+type Symbols struct {
+	// sorted by address
+	symbols []symbol
+}
+
+type symbol struct {
+	address uint16
+	name    string
+}
+
+// The symbol file of a ROM is next to it with the extension .sym, e.g. game.sym for game.gb.
+func SymbolPath(rom_path string) string {
+	return strings.TrimSuffix(rom_path, filepath.Ext(rom_path)) + ".sym"
+}
+
+func ParseSymbols(r io.Reader) (*Symbols, error) {
+	syms := &Symbols{}
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line, _, _ := strings.Cut(scanner.Text(), ";")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		bank_text, address_text, ok := strings.Cut(fields[0], ":")
+		if len(fields) != 2 || !ok {
+			return nil, fmt.Errorf("line %d: expected BB:AAAA Name", number)
+		}
+		bank, err := parseHex(bank_text, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		adr, err := parseHex(address_text, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		if adr >= 0x4000 && adr < 0x8000 && bank > 1 {
+			continue
+		}
+		syms.symbols = append(syms.symbols, symbol{uint16(adr), fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(syms.symbols, func(a, b symbol) int {
+		return int(a.address) - int(b.address)
+	})
+	return syms, nil
+}
+
+// Reads a symbol file, a missing file gives no symbols.
+func LoadSymbolFile(path string) (*Symbols, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Symbols{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSymbols(file)
+}
+
+// Names an address by the closest symbol at or before it in the same memory region,
+// e.g. "Main" or "Main+12". Returns "" if there is none.
+func (syms *Symbols) Name(adr uint16) string {
+	if syms == nil {
+		return ""
+	}
+	i, found := slices.BinarySearchFunc(syms.symbols, adr, func(sym symbol, adr uint16) int {
+		return int(sym.address) - int(adr)
+	})
+	if found {
+		return syms.symbols[i].name
+	}
+	if i == 0 || MemoryRegion(syms.symbols[i-1].address) != MemoryRegion(adr) {
+		return ""
+	}
+	sym := syms.symbols[i-1]
+	// the first of several symbols at the same address
+	for i--; i > 0 && syms.symbols[i-1].address == sym.address; i-- {
+		sym = syms.symbols[i-1]
+	}
+	return fmt.Sprintf("%s+%X", sym.name, adr-sym.address)
+}
@@ -0,0 +1,51 @@
+package maybego
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	file := `; File generated by rgblink
+00:0150 Main
+00:0150 Main.alias
+00:0160 Main.loop
+01:4000 Update
+02:4000 OtherBank
+00:C000 wCounter
+`
+	syms, err := ParseSymbols(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		adr      uint16
+		expected string
+	}{
+		{0x0150, "Main"},
+		{0x0153, "Main+3"},
+		{0x0172, "Main.loop+12"},
+		{0x4000, "Update"},
+		{0x4010, "Update+10"},
+		{0x0100, ""},
+		// the closest symbol is in ROM, not in VRAM
+		{0x8000, ""},
+		{0xC001, "wCounter+1"},
+	}
+	for _, test := range tests {
+		if name := syms.Name(test.adr); name != test.expected {
+			t.Errorf("Current name of %04X: %q; expected: %q", test.adr, name, test.expected)
+		}
+	}
+
+	if _, err := ParseSymbols(strings.NewReader("0150 Main\n")); err == nil {
+		t.Errorf("Symbol without bank accepted, expected an error")
+	}
+	if name := (*Symbols)(nil).Name(0x150); name != "" {
+		t.Errorf("Current name without symbols: %q; expected: \"\"", name)
+	}
+	if path := SymbolPath("roms/game.gb"); path != "roms/game.sym" {
+		t.Errorf("Current symbol path: %s; expected: roms/game.sym", path)
+	}
+}
@@ -0,0 +1,49 @@
+package maybego
+
+import "strings"
+
+type TestResult int
+
+const (
+	TestRunning TestResult = iota // nothing reported yet
+	TestPassed
+	TestFailed
+)
+
+// Watches a test ROM for the result it reports: Blargg's ROMs print "Passed" or
+// "Failed" over serial, Mooneye's execute LD B,B with the fibonacci numbers in B-L
+// on success and 0x42 in every register on failure.
+type TestRom struct {
+	emu       *Emulator
+	fibonacci TestResult
+}
+
+// Takes over the LD B,B hook of the CPU.
+func WatchTestRom(emu *Emulator) *TestRom {
+	test := &TestRom{emu: emu}
+	emu.cpu.SetDebugBreakpoint(func() {
+		reg := emu.cpu.reg
+		switch {
+		case reg.B == 3 && reg.C == 5 && reg.D == 8 && reg.E == 13 && reg.H == 21 && reg.L == 34:
+			test.fibonacci = TestPassed
+		case reg.B == 0x42 && reg.C == 0x42 && reg.D == 0x42 && reg.E == 0x42 && reg.H == 0x42 && reg.L == 0x42:
+			test.fibonacci = TestFailed
+		}
+	})
+	return test
+}
+
+// Returns the result reported so far.
+func (test *TestRom) Result() TestResult {
+	if test.fibonacci != TestRunning {
+		return test.fibonacci
+	}
+	output := string(test.emu.serial.GetOutput())
+	switch {
+	case strings.Contains(output, "Passed"):
+		return TestPassed
+	case strings.Contains(output, "Failed"):
+		return TestFailed
+	}
+	return TestRunning
+}
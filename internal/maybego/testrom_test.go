@@ -0,0 +1,34 @@
+package maybego
+
+import "testing"
+
+func TestTestRomResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		registers Registers
+		output    string
+		expected  TestResult
+	}{
+		{"nothing yet", Registers{}, "cpu_instrs\n\n01:ok", TestRunning},
+		{"serial passed", Registers{}, "cpu_instrs\n\nPassed all tests", TestPassed},
+		{"serial failed", Registers{}, "01:01\n\nFailed 1 tests", TestFailed},
+		{"fibonacci", Registers{B: 3, C: 5, D: 8, E: 13, H: 21, L: 34}, "", TestPassed},
+		{"fibonacci failed", Registers{B: 0x42, C: 0x42, D: 0x42, E: 0x42, H: 0x42, L: 0x42}, "", TestFailed},
+		{"other LD B,B", Registers{B: 3, C: 5}, "", TestRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Memory = [65536]byte{}
+			emu := NewEmulator(logger)
+			rom := WatchTestRom(emu)
+			*emu.cpu.reg = test.registers
+			emu.cpu.cpu40()
+			emu.serial.output = []byte(test.output)
+
+			if result := rom.Result(); result != test.expected {
+				t.Errorf("Current result: %d; expected: %d", result, test.expected)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
+//go:build !headless
+
 package maybego
 
 import (
 	"fmt"
 	"image/color"
+	"os"
 	"slices"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
@@ -24,12 +28,6 @@ const (
 )
 
 var defaultColor = color.RGBA{R: 0xFF, G: 0x80, B: 0x80, A: 0xFF}
-var Palette = []color.RGBA{
-	{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
-	{R: 0x9F, G: 0x9F, B: 0x9F, A: 0xFF},
-	{R: 0x60, G: 0x60, B: 0x60, A: 0xFF},
-	{R: 0x00, G: 0x00, B: 0x00, A: 0xFF},
-}
 
 type cpu_state_bindings struct {
 	cycles    binding.Int
@@ -49,9 +47,20 @@ type cpu_state_bindings struct {
 
 type disasmWindow struct {
 	*widget.TextGrid
-	disasm      *Disasm
-	breakpoints []uint
-	cur_pc      uint
+	disasm   *Disasm
+	debugger *Debugger
+	cur_pc   uint
+	// called when a breakpoint was toggled by tapping a line
+	on_breakpoint func()
+	// called when a jump or call was followed by right-clicking it
+	on_navigate func()
+	// addresses of the breakpoints at the last markBreakpoints
+	marked []uint16
+	// the scroll of the TextGrid, set once it is rendered
+	scroll fyne.Scrollable
+	// keeps the current PC centred
+	follow  bool
+	history disasmHistory
 }
 
 type cpuStateWindow struct {
@@ -64,6 +73,9 @@ type debugView struct {
 	disasm_win *disasmWindow
 	halt       bool
 	step       bool
+	rewind     func()
+	step_over  func()
+	step_out   func()
 }
 
 type Interface struct {
@@ -71,8 +83,34 @@ type Interface struct {
 	window  fyne.Window
 	display *canvas.Raster
 	vram    *fyne.Container
-	emu     *Emulator
-	debug   *debugView
+	memory  *memoryView
+	watch   *watchView
+	// conditional breakpoints and tracepoints
+	breakpoints *breakpointView
+	calls       *callStackView
+	debugger    *Debugger
+	emu         *Emulator
+	debug       *debugView
+	rom_path    string
+	// false if the breakpoint file of the ROM could not be read, so it is not overwritten
+	breakpoints_loaded bool
+	rewind             *Rewind
+	// rewinding while the rewind key is held
+	rewinding bool
+	keys      keyMap
+	gamepads  *Gamepads
+	// nil while the printer is not attached
+	printer     *Printer
+	printer_dir string
+}
+
+// held to step backwards one frame per tick
+const rewindKey = fyne.KeyBackspace
+
+// F1-F10 load a slot, Shift+F1-F10 save it
+var stateSlotKeys = map[fyne.KeyName]int{
+	fyne.KeyF1: 1, fyne.KeyF2: 2, fyne.KeyF3: 3, fyne.KeyF4: 4, fyne.KeyF5: 5,
+	fyne.KeyF6: 6, fyne.KeyF7: 7, fyne.KeyF8: 8, fyne.KeyF9: 9, fyne.KeyF10: 10,
 }
 
 func NewUI(logger *Logger) *Interface {
@@ -81,49 +119,97 @@ func NewUI(logger *Logger) *Interface {
 	e := NewEmulator(logger)
 	display := canvas.NewRasterWithPixels(
 		func(x, y, w, h int) color.Color {
+			if e.IsSGB() {
+				if x >= SGB_WIDTH || y >= SGB_HEIGHT {
+					return color.RGBA{R: 0, G: 0, B: 0, A: 0}
+				}
+				return rgba(e.GetSGBFrame()[(SGB_WIDTH*y)+x])
+			}
 			if x > 159 || y > 143 {
 				return color.RGBA{R: 0, G: 0, B: 0, A: 0}
 			}
-			return Palette[e.ppu.GetCurrentFrame()[(160*y)+x]]
+			return rgba(e.ppu.GetCurrentFrame()[(160*y)+x])
 		})
 
-	w.Canvas().(desktop.Canvas).SetOnKeyDown(func(ke *fyne.KeyEvent) {
-		e.PressButton(string(ke.Name))
-	})
-	w.Canvas().(desktop.Canvas).SetOnKeyUp(func(ke *fyne.KeyEvent) {
-		e.ReleaseButton(string(ke.Name))
-	})
-
 	cpu := createCpuStateWindow()
 	cpu.container.Hide()
-	disasm_container := createDisasmView()
+	debugger := NewDebugger(e)
+	disasm_container := createDisasmView(debugger)
 
 	debug := createDebugView(cpu, disasm_container)
-	debug_container := createDebugContainer(e, display, debug)
+	watch := createWatchView(e, w)
+	breakpoints := createBreakpointView(debugger, w)
+	disasm_container.on_breakpoint = breakpoints.changed
+	calls := createCallStackView(e, debugger, disasm_container)
+	navigation := createNavigationBar(disasm_container, w)
+	debug_container := createDebugContainer(e, display, debug, navigation, breakpoints, calls, watch)
 	debug_container.Hide()
 
 	vram := createVramView()
 	vram.Hide()
 
+	memory := createMemoryView(debug, w)
+	memory.container.Hide()
+
 	// TODO: scaling factor
 	display.SetMinSize(fyne.NewSize(160, 144))
-	content := container.New(layout.NewHBoxLayout(), debug_container, layout.NewSpacer(), cpu.container, layout.NewSpacer(), display, layout.NewSpacer(), vram)
+	content := container.New(layout.NewHBoxLayout(), debug_container, layout.NewSpacer(), cpu.container, layout.NewSpacer(), display, layout.NewSpacer(), vram, memory.container)
+
+	ui := &Interface{app: a, window: w, display: display, vram: vram, memory: memory, watch: watch, breakpoints: breakpoints, calls: calls, emu: e, debugger: debugger, debug: debug}
+	ui.keys = DefaultConfig().Keys.keyMap()
+	ui.printer_dir = DefaultConfig().PrinterDir
+	ui.rewind = NewRewind(DefaultRewindBudget, 1)
+	ui.debug.rewind = ui.StepBack
+	ui.debug.step_over = ui.debugger.StepOver
+	ui.debug.step_out = ui.debugger.StepOut
+	ui.breakpoints.on_change = ui.breakpointsChanged
+	ui.debug.disasm_win.ExtendBaseWidget(debug.disasm_win)
+
+	shift_pressed := false
+	w.Canvas().(desktop.Canvas).SetOnKeyDown(func(ke *fyne.KeyEvent) {
+		if ke.Name == desktop.KeyShiftLeft || ke.Name == desktop.KeyShiftRight {
+			shift_pressed = true
+		}
+		if ke.Name == rewindKey {
+			ui.rewinding = true
+			return
+		}
+		if slot, ok := stateSlotKeys[ke.Name]; ok {
+			if shift_pressed {
+				ui.SaveStateSlot(slot)
+			} else {
+				ui.LoadStateSlot(slot)
+			}
+			return
+		}
+		ui.keys.press(e, string(ke.Name))
+	})
+	w.Canvas().(desktop.Canvas).SetOnKeyUp(func(ke *fyne.KeyEvent) {
+		if ke.Name == desktop.KeyShiftLeft || ke.Name == desktop.KeyShiftRight {
+			shift_pressed = false
+		}
+		if ke.Name == rewindKey {
+			ui.rewinding = false
+			return
+		}
+		ui.keys.release(e, string(ke.Name))
+	})
 
-	debug_menu := createDebugMenu(debug_container, cpu.container, vram)
-	main_menu := fyne.NewMainMenu(debug_menu)
+	debug_menu := createDebugMenu(debug_container, cpu.container, vram, memory)
+	state_menu := createStateMenu(ui)
+	movie_menu := createMovieMenu(ui)
+	link_menu := createLinkMenu(ui)
+	hardware_menu := createHardwareMenu(ui)
+	main_menu := fyne.NewMainMenu(debug_menu, state_menu, movie_menu, link_menu, hardware_menu)
 	w.SetMainMenu(main_menu)
 	w.SetContent(content)
 
-	ui := &Interface{app: a, window: w, display: display, vram: vram, emu: e, debug: debug}
-	ui.debug.disasm_win.ExtendBaseWidget(debug.disasm_win)
-
 	return ui
 }
 
 func (ui *Interface) LoadRom(rom *[]byte) {
-	for i, buffer := range *rom {
-		Write(uint16(i), buffer)
-	}
+	ui.emu.LoadRom(rom)
+	ui.resizeDisplay()
 
 	ui.debug.disasm_win.disasm.SetFile(rom)
 
@@ -133,6 +219,7 @@ func (ui *Interface) LoadRom(rom *[]byte) {
 		for _, line := range ui.debug.disasm_win.disasm.lines {
 			ui.debug.disasm_win.Append(fmt.Sprintf("%04X|\t%s", line.offset, line.disasm))
 		}
+		ui.debug.disasm_win.markBreakpoints()
 	}()
 
 	// TODO: option to skip boot rom or not?
@@ -144,8 +231,220 @@ func (ui *Interface) LoadRom(rom *[]byte) {
 	// for i, buffer := range *rom {
 	// 	Write(uint16(i+0x100), buffer)
 	// }
-	ui.emu.rom_loaded = true
+}
+
+func (ui *Interface) SetConfig(config *Config) {
+	ui.keys = config.Keys.keyMap()
+	macros, err := config.macros()
+	if err != nil {
+		fmt.Println("Some macros could not be read")
+		fmt.Println(err)
+	}
+	ui.keys.macros = macros
+	ui.emu.SetTurboRate(config.TurboRate)
+	ui.printer_dir = config.PrinterDir
+
+	ui.gamepads.Close()
+	gamepads, err := OpenGamepads(ui.emu, &config.Gamepad, macros)
+	if err != nil && err != ErrNoGamepadSupport {
+		fmt.Println("Controllers could not be opened")
+		fmt.Println(err)
+	}
+	ui.gamepads = gamepads
+}
+
+func (ui *Interface) ConnectLink(cable LinkCable) {
+	ui.printer = nil
+	ui.emu.ConnectLink(cable)
+}
+
+// Attaches the Game Boy Printer to the serial port, or detaches it.
+func (ui *Interface) TogglePrinter() {
+	if ui.printer != nil {
+		err := ui.printer.Close()
+		ui.ConnectLink(nil)
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+		return
+	}
+	printer := NewPrinter(ui.printer_dir)
+	ui.ConnectLink(printer)
+	ui.printer = printer
+}
+
+// Save state slots are stored next to the ROM as <rom>.ss<slot>,
+// breakpoints as <rom>.breakpoints, which are loaded here with the symbols.
+func (ui *Interface) SetRomPath(path string) {
+	ui.rom_path = path
+	err := ui.debugger.LoadBreakpointFile(BreakpointPath(path))
+	if err != nil {
+		fmt.Println("Breakpoints could not be loaded")
+		fmt.Println(err)
+	}
+	ui.breakpoints_loaded = err == nil
+	ui.breakpoints.list.Refresh()
+	ui.debug.disasm_win.markBreakpoints()
+
+	symbols, err := LoadSymbolFile(SymbolPath(path))
+	if err != nil {
+		fmt.Println("Symbols could not be loaded")
+		fmt.Println(err)
+	}
+	ui.debugger.SetSymbols(symbols)
+}
+
+// Marks the changed breakpoints in the disassembly and saves them, unless
+// the file of the ROM could not be read.
+func (ui *Interface) breakpointsChanged() {
+	ui.debug.disasm_win.markBreakpoints()
+	if ui.rom_path == "" || !ui.breakpoints_loaded {
+		return
+	}
+	if err := ui.debugger.SaveBreakpointFile(BreakpointPath(ui.rom_path)); err != nil {
+		dialog.ShowError(err, ui.window)
+	}
+}
+
+func (ui *Interface) statePath(slot int) string {
+	return fmt.Sprintf("%s.ss%d", ui.rom_path, slot)
+}
+
+func (ui *Interface) SaveStateSlot(slot int) {
+	file, err := os.Create(ui.statePath(slot))
+	if err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	defer file.Close()
+
+	if err := ui.emu.SaveState(file); err != nil {
+		dialog.ShowError(err, ui.window)
+	}
+}
+
+func (ui *Interface) LoadStateSlot(slot int) {
+	if err := ui.LoadStateFile(ui.statePath(slot)); err != nil {
+		dialog.ShowError(err, ui.window)
+	}
+}
+
+func (ui *Interface) LoadStateFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := ui.emu.LoadState(file); err != nil {
+		return err
+	}
+
+	ui.refreshAfterJump()
+	return nil
+}
+
+func (ui *Interface) refreshAfterJump() {
+	ui.display.Refresh()
+	ui.debug.disasm_win.updatePC(uint(ui.emu.GetCPUState().registers.PC))
+	ui.calls.refresh()
+	if ui.debug.cpu_win.container.Visible() {
+		ui.SetCPUState()
+	}
+	if ui.memory.container.Visible() {
+		ui.memory.step()
+	}
+}
+
+// Switches the emulated hardware and restarts the ROM.
+func (ui *Interface) SetModel(model Model) {
+	ui.emu.SetModel(model)
+	if ui.emu.rom_loaded {
+		ui.emu.StopMovie()
+		ui.emu.PowerOn()
+		ui.rewind.Reset()
+	}
+	ui.resizeDisplay()
+	ui.refreshAfterJump()
+}
+
+// The SGB shows the border around the game screen.
+func (ui *Interface) resizeDisplay() {
+	if ui.emu.IsSGB() {
+		ui.display.SetMinSize(fyne.NewSize(SGB_WIDTH, SGB_HEIGHT))
+	} else {
+		ui.display.SetMinSize(fyne.NewSize(160, 144))
+	}
+	ui.display.Refresh()
+}
+
+func (ui *Interface) RecordMovie(from_state bool) {
+	ui.emu.StopMovie()
+	if err := ui.emu.StartRecording(from_state); err != nil {
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	ui.refreshAfterJump()
+}
+
+// Stops recording and asks where to save the movie.
+func (ui *Interface) StopMovie() {
+	movie := ui.emu.StopMovie()
+	if movie == nil {
+		return
+	}
+
+	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := movie.Write(writer); err != nil {
+			dialog.ShowError(err, ui.window)
+		}
+	}, ui.window)
+}
+
+func (ui *Interface) PlayMovie() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		movie, err := ReadMovie(reader)
+		if err == nil {
+			ui.emu.StopMovie()
+			err = ui.emu.PlayMovie(movie)
+		}
+		if err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		ui.refreshAfterJump()
+	}, ui.window)
+}
 
+// Keeps at most budget bytes of rewind history, 0 disables rewinding.
+func (ui *Interface) SetRewindBudget(budget int) {
+	ui.rewind = NewRewind(budget, 1)
+}
+
+func (ui *Interface) StepBack() {
+	if err := ui.rewind.Step(ui.emu); err != nil {
+		return
+	}
+
+	ui.refreshAfterJump()
 }
 
 func (ui *Interface) SetCPUState() {
@@ -214,6 +513,11 @@ func (ui *Interface) Run() {
 				continue
 			}
 			fyne.DoAndWait(func() {
+				ui.gamepads.Poll()
+				if ui.rewinding {
+					ui.StepBack()
+					return
+				}
 
 				frame_ready := false
 				max_render_time := (456 /* dots */ * 153 /* lines */ / 4 /* cpu cyc */)
@@ -222,23 +526,36 @@ func (ui *Interface) Run() {
 					ui.debug.halt = true
 				}
 				for _ = range max_render_time {
+					// before the instruction runs, also the first one of a frame
+					if ui.debugger.Check() {
+						ui.debug.halt = true
+						ui.breakpoints.list.Refresh()
+						ui.debug.disasm_win.updatePC(uint(ui.emu.GetCPUState().registers.PC))
+						break
+					}
 					frame_ready = ui.emu.Run()
+					if hit := ui.emu.WatchHit(); hit != nil {
+						ui.debug.halt = true
+						ui.watch.showHit(hit)
+						ui.debug.disasm_win.updatePC(uint(ui.emu.GetCPUState().registers.PC))
+					}
 					if frame_ready {
 						break
 					}
-					next_pc := uint(ui.emu.GetCPUState().registers.PC)
 					if ui.debug.halt {
-						ui.debug.disasm_win.updatePC(next_pc)
-						break
-					}
-					if slices.Contains(ui.debug.disasm_win.breakpoints, next_pc) {
-						ui.debug.halt = true
-						ui.debug.disasm_win.updatePC(next_pc)
+						ui.debug.disasm_win.updatePC(uint(ui.emu.GetCPUState().registers.PC))
 						break
 					}
 				}
 				if frame_ready {
 					ui.display.Refresh()
+					ui.rewind.Capture(ui.emu)
+				}
+				if ui.debug.halt {
+					ui.calls.refresh()
+				}
+				if ui.memory.container.Visible() && (frame_ready || ui.debug.halt) {
+					ui.memory.step()
 				}
 
 				if ui.debug.cpu_win.container.Visible() {
@@ -248,6 +565,7 @@ func (ui *Interface) Run() {
 		}
 	}()
 	ui.window.ShowAndRun()
+	ui.gamepads.Close()
 
 }
 
@@ -332,10 +650,11 @@ func createCpuStateWindow() *cpuStateWindow {
 	return cpu
 }
 
-func createDisasmView() *disasmWindow {
+func createDisasmView(debugger *Debugger) *disasmWindow {
 	disasm_container := &disasmWindow{
 		TextGrid: &widget.TextGrid{},
 		disasm:   NewDisasm(),
+		debugger: debugger,
 	}
 	disasm_container.Scroll = fyne.ScrollVerticalOnly
 	return disasm_container
@@ -352,7 +671,7 @@ func createDebugView(cpu *cpuStateWindow, disasm *disasmWindow) *debugView {
 	return debug
 }
 
-func createDebugContainer(emu *Emulator, display *canvas.Raster, debug *debugView) *fyne.Container {
+func createDebugContainer(emu *Emulator, display *canvas.Raster, debug *debugView, navigation *fyne.Container, breakpoints *breakpointView, calls *callStackView, watch *watchView) *fyne.Container {
 	toolbar := widget.NewToolbar(
 		widget.NewToolbarAction(theme.MediaPauseIcon(), func() {
 			debug.halt = true
@@ -365,6 +684,21 @@ func createDebugContainer(emu *Emulator, display *canvas.Raster, debug *debugVie
 			debug.halt = false
 			debug.step = false
 		}),
+		// step over and out run until the debugger halts
+		widget.NewToolbarAction(theme.MediaSkipNextIcon(), func() {
+			debug.step_over()
+			debug.halt = false
+			debug.step = false
+		}),
+		widget.NewToolbarAction(theme.MoveUpIcon(), func() {
+			debug.step_out()
+			debug.halt = false
+			debug.step = false
+		}),
+		widget.NewToolbarAction(theme.MediaSkipPreviousIcon(), func() {
+			debug.halt = true
+			debug.rewind()
+		}),
 		widget.NewToolbarSpacer(),
 		widget.NewToolbarSeparator(),
 		widget.NewToolbarAction(theme.MediaReplayIcon(), func() {
@@ -374,10 +708,49 @@ func createDebugContainer(emu *Emulator, display *canvas.Raster, debug *debugVie
 		}),
 	)
 
-	return container.NewBorder(toolbar, nil, nil, nil, debug.disasm_win)
+	return container.NewBorder(container.NewVBox(toolbar, navigation), container.NewVBox(breakpoints.container, calls.container, watch.container), nil, nil, debug.disasm_win)
+}
+
+// Back and forward through the history of the disassembly, go to an address and follow the PC.
+// Going anywhere but to the PC stops following it.
+func createNavigationBar(dw *disasmWindow, window fyne.Window) *fyne.Container {
+	follow := widget.NewCheck("Follow PC", func(follow bool) {
+		dw.follow = follow
+		if follow {
+			dw.scrollToLine(dw.pcToLine(dw.cur_pc))
+		}
+	})
+	follow.SetChecked(true)
+	dw.on_navigate = func() { follow.SetChecked(false) }
+
+	address := widget.NewEntry()
+	address.SetPlaceHolder("go to address")
+	go_to := func() {
+		adr, err := parseHex(address.Text, 16)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		dw.show(uint16(adr))
+		dw.on_navigate()
+	}
+	address.OnSubmitted = func(string) { go_to() }
+
+	back := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		if dw.back() {
+			dw.on_navigate()
+		}
+	})
+	forward := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		if dw.forward() {
+			dw.on_navigate()
+		}
+	})
+	return container.NewBorder(nil, nil, container.NewHBox(back, forward),
+		container.NewHBox(widget.NewButton("Go", go_to), follow), address)
 }
 
-func createDebugMenu(debug_container *fyne.Container, cpu_container *fyne.Container, vram *fyne.Container) *fyne.Menu {
+func createDebugMenu(debug_container *fyne.Container, cpu_container *fyne.Container, vram *fyne.Container, memory *memoryView) *fyne.Menu {
 	var debug_visibility *fyne.MenuItem
 	var disasm_visibility *fyne.MenuItem
 	var cpu_state_visibility *fyne.MenuItem
@@ -431,18 +804,178 @@ func createDebugMenu(debug_container *fyne.Container, cpu_container *fyne.Contai
 		vram_visibility.Checked = vram.Visible()
 	})
 	vram_visibility.Checked = vram.Visible()
+	var memory_visibility *fyne.MenuItem
+	memory_visibility = fyne.NewMenuItem("Memory viewer", func() {
+		if memory.container.Hidden {
+			memory.step()
+			memory.container.Show()
+		} else {
+			memory.container.Hide()
+		}
+		memory_visibility.Checked = memory.container.Visible()
+	})
+
+	return fyne.NewMenu("Debug", debug_visibility, disasm_visibility, cpu_state_visibility, vram_visibility, memory_visibility)
+}
+
+func createStateMenu(ui *Interface) *fyne.Menu {
+	save_menu := fyne.NewMenu("Save")
+	load_menu := fyne.NewMenu("Load")
+	for slot := 1; slot <= len(stateSlotKeys); slot++ {
+		save_menu.Items = append(save_menu.Items, fyne.NewMenuItem(fmt.Sprintf("Slot %d (Shift+F%d)", slot, slot), func() {
+			ui.SaveStateSlot(slot)
+		}))
+		load_menu.Items = append(load_menu.Items, fyne.NewMenuItem(fmt.Sprintf("Slot %d (F%d)", slot, slot), func() {
+			ui.LoadStateSlot(slot)
+		}))
+	}
 
-	return fyne.NewMenu("Debug", debug_visibility, disasm_visibility, cpu_state_visibility, vram_visibility)
+	save_item := fyne.NewMenuItem("Save state", nil)
+	save_item.ChildMenu = save_menu
+	load_item := fyne.NewMenuItem("Load state", nil)
+	load_item.ChildMenu = load_menu
+
+	return fyne.NewMenu("State", save_item, load_item)
+}
+
+func createHardwareMenu(ui *Interface) *fyne.Menu {
+	models := []Model{ModelAuto, ModelDMG, ModelCGB, ModelSGB}
+	names := []string{"Auto (ROM header)", "Force DMG", "Force CGB", "Force SGB"}
+	items := make([]*fyne.MenuItem, len(models))
+	for i, model := range models {
+		items[i] = fyne.NewMenuItem(names[i], func() {
+			ui.SetModel(model)
+			for j := range items {
+				items[j].Checked = j == i
+			}
+		})
+	}
+	items[0].Checked = true
+	return fyne.NewMenu("Hardware", items...)
+}
+
+func createLinkMenu(ui *Interface) *fyne.Menu {
+	var printer_item *fyne.MenuItem
+	printer_item = fyne.NewMenuItem("Game Boy Printer", func() {
+		ui.TogglePrinter()
+		printer_item.Checked = ui.printer != nil
+	})
+	return fyne.NewMenu("Link", printer_item)
+}
+
+func createMovieMenu(ui *Interface) *fyne.Menu {
+	return fyne.NewMenu("Movie",
+		fyne.NewMenuItem("Record from power-on", func() { ui.RecordMovie(false) }),
+		fyne.NewMenuItem("Record from current state", func() { ui.RecordMovie(true) }),
+		fyne.NewMenuItem("Stop and save...", ui.StopMovie),
+		fyne.NewMenuItem("Play...", ui.PlayMovie),
+	)
+}
+
+// Keeps the scroll of the TextGrid, which has no way to scroll to a row.
+func (dw *disasmWindow) CreateRenderer() fyne.WidgetRenderer {
+	renderer := dw.TextGrid.CreateRenderer()
+	dw.scroll, _ = renderer.Objects()[0].(fyne.Scrollable)
+	return renderer
+}
+
+// Scrolls so the line is in the middle.
+func (dw *disasmWindow) scrollToLine(line int) {
+	if dw.scroll == nil || line < 0 || line >= len(dw.disasm.lines) {
+		return
+	}
+	top := dw.PositionForCursorLocation(0, 0).Y
+	height := dw.PositionForCursorLocation(1, 0).Y - top
+	target := float32(line)*height - (dw.Size().Height-height)/2
+	// scrolling by an event, so the TextGrid adds the rows that became visible
+	dw.scroll.Scrolled(&fyne.ScrollEvent{Scrolled: fyne.Delta{DY: -top - target}})
+}
+
+// Scrolls to an address and remembers it in the history.
+func (dw *disasmWindow) show(adr uint16) {
+	if len(dw.history.entries) == 0 {
+		dw.history.visit(uint16(dw.cur_pc))
+	}
+	dw.history.visit(adr)
+	dw.scrollToLine(dw.pcToLine(uint(adr)))
+}
+
+func (dw *disasmWindow) back() bool {
+	adr, ok := dw.history.back()
+	if ok {
+		dw.scrollToLine(dw.pcToLine(uint(adr)))
+	}
+	return ok
+}
+
+func (dw *disasmWindow) forward() bool {
+	adr, ok := dw.history.forward()
+	if ok {
+		dw.scrollToLine(dw.pcToLine(uint(adr)))
+	}
+	return ok
 }
 
+// Right-clicking a jump, call or RST goes to its target, back returns to the line.
+func (dw *disasmWindow) TappedSecondary(ev *fyne.PointEvent) {
+	xpos, _ := dw.CursorLocationForPosition(ev.Position)
+	if xpos < 0 || xpos >= len(dw.disasm.lines) {
+		return
+	}
+	offset := dw.disasm.lines[xpos].offset
+	target, ok := dw.disasm.Target(offset)
+	if !ok {
+		return
+	}
+	dw.history.visit(uint16(offset))
+	dw.show(target)
+	if dw.on_navigate != nil {
+		dw.on_navigate()
+	}
+}
+
+// Tapping a line sets a breakpoint on it, tapping it again removes it.
 func (dw *disasmWindow) Tapped(ev *fyne.PointEvent) {
 	xpos, _ := dw.CursorLocationForPosition(ev.Position)
+	if xpos < 0 || xpos >= len(dw.disasm.lines) {
+		return
+	}
 
-	selectedStyle := widget.CustomTextGridStyle{}
-	selectedStyle.BGColor = theme.Color(theme.ColorNameFocus)
-	// TODO visual indication that it is selected instantly
-	dw.breakpoints = append(dw.breakpoints, dw.disasm.lines[xpos].offset)
-	dw.SetRowStyle(xpos, &selectedStyle)
+	dw.debugger.ToggleBreakpoint(uint16(dw.disasm.lines[xpos].offset))
+	if dw.on_breakpoint != nil {
+		dw.on_breakpoint()
+	}
+	dw.markBreakpoints()
+}
+
+// Highlights a line if the PC or a breakpoint is on it, disabled breakpoints are greyed.
+func (dw *disasmWindow) markLine(line int) {
+	if line < 0 || line >= len(dw.disasm.lines) {
+		return
+	}
+	var style widget.TextGridStyle = widget.TextGridStyleDefault
+	bp := dw.debugger.BreakpointAt(uint16(dw.disasm.lines[line].offset))
+	switch {
+	case line == dw.pcToLine(dw.cur_pc):
+		style = &widget.CustomTextGridStyle{BGColor: theme.Color(theme.ColorNameError)}
+	case bp != nil && !bp.Disabled:
+		style = &widget.CustomTextGridStyle{BGColor: theme.Color(theme.ColorNameFocus)}
+	case bp != nil:
+		style = &widget.CustomTextGridStyle{BGColor: theme.Color(theme.ColorNameDisabled)}
+	}
+	dw.SetRowStyle(line, style)
+}
+
+// Marks the lines of the breakpoints and unmarks the ones removed since the last call.
+func (dw *disasmWindow) markBreakpoints() {
+	previous := dw.marked
+	dw.marked = nil
+	for _, bp := range dw.debugger.Breakpoints() {
+		dw.marked = append(dw.marked, bp.Address)
+	}
+	for _, adr := range append(previous, dw.marked...) {
+		dw.markLine(dw.pcToLine(uint(adr)))
+	}
 	dw.BaseWidget.Refresh()
 }
 
@@ -454,11 +987,18 @@ func (dw *disasmWindow) pcToLine(pc uint) int {
 }
 
 func (dw *disasmWindow) updatePC(pc uint) {
-	defaultStyle := widget.TextGridStyleDefault
-	selectedStyle := widget.CustomTextGridStyle{}
-	selectedStyle.BGColor = theme.Color(theme.ColorNameError)
-	dw.SetRowStyle(dw.pcToLine(dw.cur_pc), defaultStyle)
+	previous := dw.pcToLine(dw.cur_pc)
+	current := dw.pcToLine(pc)
+	if dw.follow && current != previous && current != previous+1 {
+		// jumps and calls go into the history
+		dw.history.visit(uint16(dw.cur_pc))
+		dw.history.visit(uint16(pc))
+	}
 	dw.cur_pc = pc
-	dw.SetRowStyle(dw.pcToLine(dw.cur_pc), &selectedStyle)
+	dw.markLine(previous)
+	dw.markLine(current)
+	if dw.follow {
+		dw.scrollToLine(current)
+	}
 	dw.BaseWidget.Refresh()
 }
@@ -0,0 +1,154 @@
+package maybego
+
+import (
+	"fmt"
+	"strings"
+)
+
+type WatchKind byte
+
+const (
+	WatchRead   WatchKind = 1 << iota
+	WatchWrite            // every write, also of the value already there
+	WatchChange           // only writes that change the value
+)
+
+// Breaks when the CPU accesses memory between Start and End, both included.
+type Watchpoint struct {
+	Start uint16
+	End   uint16
+	Kind  WatchKind
+	// with HasValue set, only accesses of this value (read or written) break
+	Value    byte
+	HasValue bool
+}
+
+// The access that triggered a watchpoint.
+type WatchHit struct {
+	Watchpoint Watchpoint
+	Kind       WatchKind
+	Address    uint16
+	// start of the instruction that accessed memory
+	PC    uint16
+	Old   byte // before a write
+	Value byte // read or written
+}
+
+// The watchpoints of an emulator. Only accesses of CPU instructions count,
+// the operands of an instruction are reads as well.
+type watchState struct {
+	points []Watchpoint
+	cpu    bool
+	pc     uint16
+	// the first hit since the emulator was last asked
+	hit *WatchHit
+}
+
+// The watchpoints of the running emulator, checked by Read and Write.
+var busWatch *watchState
+
+func (emu *Emulator) AddWatchpoint(point Watchpoint) {
+	emu.watch.points = append(emu.watch.points, point)
+}
+
+func (emu *Emulator) RemoveWatchpoint(index int) {
+	if index >= 0 && index < len(emu.watch.points) {
+		emu.watch.points = append(emu.watch.points[:index], emu.watch.points[index+1:]...)
+	}
+}
+
+func (emu *Emulator) Watchpoints() []Watchpoint {
+	return emu.watch.points
+}
+
+// Returns the watchpoint hit since the last call, or nil.
+// Run and RunFrame stop right after the instruction that hit it.
+func (emu *Emulator) WatchHit() *WatchHit {
+	hit := emu.watch.hit
+	emu.watch.hit = nil
+	return hit
+}
+
+func (point *Watchpoint) matches(kind WatchKind, adr uint16, val byte) bool {
+	return point.Kind&kind != 0 && adr >= point.Start && adr <= point.End &&
+		(!point.HasValue || point.Value == val)
+}
+
+func (watch *watchState) active() bool {
+	return watch.cpu && len(watch.points) > 0 && watch.hit == nil
+}
+
+func (watch *watchState) check(kind WatchKind, adr uint16, old byte, val byte) {
+	for _, point := range watch.points {
+		if point.matches(kind, adr, val) {
+			watch.hit = &WatchHit{Watchpoint: point, Kind: kind, Address: adr, PC: watch.pc, Old: old, Value: val}
+			return
+		}
+	}
+}
+
+func (watch *watchState) read(adr uint16, val byte) {
+	watch.check(WatchRead, adr, val, val)
+}
+
+func (watch *watchState) write(adr uint16, old byte, val byte) {
+	kind := WatchWrite
+	if old != val {
+		kind |= WatchChange
+	}
+	watch.check(kind, adr, old, val)
+}
+
+// Parses a single address or an inclusive range like "C000-C0FF", in hex.
+func ParseAddressRange(text string) (uint16, uint16, error) {
+	start_text, end_text, is_range := strings.Cut(text, "-")
+	start, err := parseHex(start_text, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := start
+	if is_range {
+		if end, err = parseHex(end_text, 16); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %s ends before it starts", text)
+	}
+	return uint16(start), uint16(end), nil
+}
+
+func (hit *WatchHit) String() string {
+	switch {
+	case hit.Kind&WatchRead != 0:
+		return fmt.Sprintf("read %04X = %02X at PC %04X", hit.Address, hit.Value, hit.PC)
+	case hit.Old != hit.Value:
+		return fmt.Sprintf("write %04X = %02X (was %02X) at PC %04X", hit.Address, hit.Value, hit.Old, hit.PC)
+	}
+	return fmt.Sprintf("write %04X = %02X at PC %04X", hit.Address, hit.Value, hit.PC)
+}
+
+func (kind WatchKind) String() string {
+	switch {
+	case kind&WatchRead != 0 && kind&WatchWrite != 0:
+		return "read/write"
+	case kind&WatchRead != 0:
+		return "read"
+	case kind&WatchWrite != 0:
+		return "write"
+	case kind&WatchChange != 0:
+		return "change"
+	}
+	return "none"
+}
+
+func (point Watchpoint) String() string {
+	text := fmt.Sprintf("%04X %s", point.Start, point.Kind)
+	if point.End != point.Start {
+		text = fmt.Sprintf("%04X-%04X %s", point.Start, point.End, point.Kind)
+	}
+	if point.HasValue {
+		text += fmt.Sprintf(" = %02X", point.Value)
+	}
+	return text
+}
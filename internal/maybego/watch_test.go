@@ -0,0 +1,147 @@
+package maybego
+
+import (
+	"testing"
+)
+
+// LD A,(C000); LD (C001),A; LD (C001),A
+var watchProgram = []byte{0xFA, 0x00, 0xC0, 0xEA, 0x01, 0xC0, 0xEA, 0x01, 0xC0}
+
+func loadWatchProgram() *Emulator {
+	emu := loadProgram(programRom(watchProgram...), ModelAuto)
+	DebugWrite(0xC000, 0x42)
+	return emu
+}
+
+func TestWatchpoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		point Watchpoint
+		// written to C001 before the program runs
+		initial  byte
+		expected *WatchHit
+	}{
+		{"read", Watchpoint{Start: 0xC000, End: 0xC000, Kind: WatchRead}, 0, &WatchHit{Kind: WatchRead, Address: 0xC000, PC: 0x100, Old: 0x42, Value: 0x42}},
+		{"operand", Watchpoint{Start: 0x0102, End: 0x0102, Kind: WatchRead}, 0, &WatchHit{Kind: WatchRead, Address: 0x0102, PC: 0x100, Old: 0xC0, Value: 0xC0}},
+		{"write", Watchpoint{Start: 0xC001, End: 0xC001, Kind: WatchWrite}, 0, &WatchHit{Kind: WatchWrite | WatchChange, Address: 0xC001, PC: 0x103, Old: 0x00, Value: 0x42}},
+		{"write same value", Watchpoint{Start: 0xC001, End: 0xC001, Kind: WatchWrite}, 0x42, &WatchHit{Kind: WatchWrite, Address: 0xC001, PC: 0x103, Old: 0x42, Value: 0x42}},
+		{"change", Watchpoint{Start: 0xC000, End: 0xC0FF, Kind: WatchChange}, 0, &WatchHit{Kind: WatchWrite | WatchChange, Address: 0xC001, PC: 0x103, Old: 0x00, Value: 0x42}},
+		{"no change", Watchpoint{Start: 0xC000, End: 0xC0FF, Kind: WatchChange}, 0x42, nil},
+		{"value", Watchpoint{Start: 0xC000, End: 0xC000, Kind: WatchRead, Value: 0x41, HasValue: true}, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			emu := loadWatchProgram()
+			DebugWrite(0xC001, test.initial)
+			emu.AddWatchpoint(test.point)
+
+			var hit *WatchHit
+			for range 3 {
+				emu.Run()
+				if hit = emu.WatchHit(); hit != nil {
+					break
+				}
+			}
+
+			if test.expected == nil {
+				if hit != nil {
+					t.Errorf("Got hit %s, expected none", hit)
+				}
+				return
+			}
+			test.expected.Watchpoint = test.point
+			if hit == nil || *hit != *test.expected {
+				t.Errorf("Got hit %+v, expected %+v", hit, test.expected)
+			}
+		})
+	}
+}
+
+func TestWatchpointIgnoresPPU(t *testing.T) {
+	emu := loadWatchProgram()
+	emu.AddWatchpoint(Watchpoint{Start: LCDC, End: LY, Kind: WatchRead | WatchWrite})
+
+	if !emu.RunFrame() {
+		t.Error("RunFrame stopped without a frame")
+	}
+	if hit := emu.WatchHit(); hit != nil {
+		t.Errorf("Got hit %s, expected none", hit)
+	}
+}
+
+func TestRunFrameStopsAtWatchpoint(t *testing.T) {
+	emu := loadWatchProgram()
+	emu.AddWatchpoint(Watchpoint{Start: 0xC001, End: 0xC001, Kind: WatchWrite})
+
+	if emu.RunFrame() {
+		t.Error("RunFrame finished the frame after a watchpoint hit")
+	}
+	if emu.cpu.reg.PC != 0x106 {
+		t.Errorf("Current PC: %x; expected: %x", emu.cpu.reg.PC, 0x106)
+	}
+	emu.RemoveWatchpoint(0)
+	emu.WatchHit()
+	if !emu.RunFrame() {
+		t.Error("RunFrame stopped after the watchpoint was removed")
+	}
+}
+
+func TestParseAddressRange(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end uint16
+		fails      bool
+	}{
+		{"C000", 0xC000, 0xC000, false},
+		{"0xff40-$FF4B", 0xFF40, 0xFF4B, false},
+		{"C0FF-C000", 0, 0, true},
+		{"10000", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		start, end, err := ParseAddressRange(test.text)
+		if (err != nil) != test.fails {
+			t.Errorf("%q: got error %v, expected failure %t", test.text, err, test.fails)
+		}
+		if start != test.start || end != test.end {
+			t.Errorf("%q: current range: %x-%x; expected: %x-%x", test.text, start, end, test.start, test.end)
+		}
+	}
+}
+
+func TestWatchpointOnInterruptPush(t *testing.T) {
+	emu := loadWatchProgram()
+	Write(IE, 0x01)
+	Write(IF, 0x01)
+	emu.cpu.flg.IME = true
+	emu.AddWatchpoint(Watchpoint{Start: 0xFFFC, End: 0xFFFD, Kind: WatchWrite})
+
+	emu.Run()
+	hit := emu.WatchHit()
+	if hit == nil || hit.Address != 0xFFFD || hit.PC != 0x100 || hit.Value != 0x01 {
+		t.Errorf("Current hit: %+v; expected: the push of 01 to FFFD at 100", hit)
+	}
+}
+
+// A VRAM DMA started by an instruction copies on its own, its reads are not the instruction's.
+func TestWatchpointIgnoresVramDMA(t *testing.T) {
+	rom := programRom(watchProgram...)
+	rom[CGB_FLAG] = 0x80
+	emu := loadProgram(rom, ModelAuto)
+	t.Cleanup(func() { cgb = cgbMemory{} })
+	fillDMASource(0xC000, 0x10)
+	emu.AddWatchpoint(Watchpoint{Start: 0xC000, End: 0xC00F, Kind: WatchRead})
+	emu.AddWatchpoint(Watchpoint{Start: 0x8100, End: 0x810F, Kind: WatchWrite})
+
+	emu.watch.pc, emu.watch.cpu = 0x100, true
+	Write(HDMA5, 0x00)
+	emu.watch.cpu = false
+	if hit := emu.WatchHit(); hit != nil {
+		t.Errorf("Current hit: %+v; expected: none", hit)
+	}
+	if vramRead(0, 0x8100) != 0x01 {
+		t.Errorf("Current VRAM at 8100: %x; expected: the DMA to have copied 01", vramRead(0, 0x8100))
+	}
+}
@@ -0,0 +1,82 @@
+//go:build !headless
+
+package maybego
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+var watchKinds = map[string]WatchKind{
+	"write":      WatchWrite,
+	"read":       WatchRead,
+	"read/write": WatchRead | WatchWrite,
+	"change":     WatchChange,
+}
+
+// Lists the watchpoints of the emulator with a form to add more,
+// and the access that hit one last.
+type watchView struct {
+	container *fyne.Container
+	list      *widget.List
+	hit       *widget.Label
+	emu       *Emulator
+	window    fyne.Window
+}
+
+func createWatchView(emu *Emulator, window fyne.Window) *watchView {
+	view := &watchView{emu: emu, window: window, hit: widget.NewLabel("")}
+	view.list = widget.NewList(
+		func() int { return len(emu.Watchpoints()) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", theme.DeleteIcon(), nil), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(emu.Watchpoints()[id].String())
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				emu.RemoveWatchpoint(id)
+				view.list.Refresh()
+			}
+		},
+	)
+
+	address := widget.NewEntry()
+	address.SetPlaceHolder("C000-C0FF")
+	kind := widget.NewSelect([]string{"write", "read", "read/write", "change"}, nil)
+	kind.SetSelected("write")
+	value := widget.NewEntry()
+	value.SetPlaceHolder("any value")
+	add := widget.NewButtonWithIcon("Watch", theme.ContentAddIcon(), func() {
+		start, end, err := ParseAddressRange(address.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		point := Watchpoint{Start: start, End: end, Kind: watchKinds[kind.Selected]}
+		if value.Text != "" {
+			val, err := parseHex(value.Text, 8)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			point.Value, point.HasValue = byte(val), true
+		}
+		emu.AddWatchpoint(point)
+		view.list.Refresh()
+	})
+
+	title := widget.NewLabel("Watchpoints")
+	title.TextStyle.Bold = true
+	form := container.NewGridWithColumns(4, address, kind, value, add)
+	scroll := container.NewGridWrap(fyne.NewSize(360, 100), view.list)
+	view.container = container.NewVBox(title, form, scroll, view.hit)
+	return view
+}
+
+func (view *watchView) showHit(hit *WatchHit) {
+	view.hit.SetText("Hit: " + hit.String())
+}
@@ -18,6 +18,7 @@ pkgs.mkShell {
     pkgs.xorg.libXxf86vm
     pkgs.libxkbcommon
     pkgs.wayland
+    pkgs.SDL2
   ];
 
   hardeningDisable = [ "fortify" ];